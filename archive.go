@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const maxSearchResults = 100
+
+// historyRadius is how many messages either side of a search result are shown
+// when jumping to it
+const historyRadius = 200
+
+// ArchivedMessage is a pub'd message as it is kept in the local archive.
+// Channel is the channel's at-uri when we know it and its wsurl otherwise.
+// Handle is the handle the appview signed for when there is a signet, and the
+// one claimed over lrc when there isn't
+type ArchivedMessage struct {
+	Channel   string    `json:"channel"`
+	WSURL     string    `json:"wsurl"`
+	Title     *string   `json:"title,omitempty"`
+	Time      time.Time `json:"time"`
+	LrcID     uint32    `json:"lrcID"`
+	Nick      *string   `json:"nick,omitempty"`
+	Handle    *string   `json:"handle,omitempty"`
+	Color     *uint32   `json:"color,omitempty"`
+	SignetURI *string   `json:"signetURI,omitempty"`
+	Body      string    `json:"body"`
+}
+
+// Archive keeps every message that gets pub'd in a channel we are in, one
+// jsonl file per channel, so that they can be searched after the program
+// exits. each file has an index next to it that is brought up to date when
+// it is searched
+type Archive struct {
+	dir string
+
+	// mu guards broken, indexes and the files, searches run in the
+	// background while messages are still being added and an archive can be
+	// shared between ssh sessions
+	mu      sync.Mutex
+	broken  bool
+	indexes map[string]*archiveIndex
+}
+
+var errArchiveOff = errors.New("the archive is off for this session")
+
+type SearchResult struct {
+	Message *ArchivedMessage
+	Score   float64
+	path    string
+	line    int
+}
+
+func openArchive() (*Archive, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return &Archive{broken: true}, err
+	}
+	return openArchiveIn(dir), nil
+}
+
+// openArchiveIn opens the archive kept in dir
+func openArchiveIn(dir string) *Archive {
+	return &Archive{dir: filepath.Join(dir, "archive")}
+}
+
+func (a *Archive) path(channel string) string {
+	return filepath.Join(a.dir, spillName(channel)+".jsonl")
+}
+
+// add appends am to its channel's file. the first failure turns the archive
+// off for the rest of the session so that a full disk is only reported once
+func (a *Archive) add(am *ArchivedMessage) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.off() {
+		return nil
+	}
+	err := a.append(am)
+	if err != nil {
+		a.broken = true
+	}
+	return err
+}
+
+func (a *Archive) append(am *ArchivedMessage) error {
+	data, err := json.Marshal(am)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(a.dir, 0o700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.path(am.Channel), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// off reports whether the archive can't be used, because there was nowhere to
+// keep it or because writing to it failed. a must be locked
+func (a *Archive) off() bool {
+	return a.broken || a.dir == ""
+}
+
+// index returns the index of the file at path, up to date with everything
+// that has been appended to it. a must be locked
+func (a *Archive) index(path string) (*archiveIndex, error) {
+	if a.indexes == nil {
+		a.indexes = make(map[string]*archiveIndex)
+	}
+	idx, ok := a.indexes[path]
+	if !ok {
+		idx = loadArchiveIndex(path)
+		a.indexes[path] = idx
+	}
+	grew, err := idx.catchUp(path)
+	if err != nil {
+		return nil, err
+	}
+	if grew {
+		// an index that can't be saved is rebuilt next time, which is only
+		// slow
+		idx.save(indexPath(path))
+	}
+	return idx, nil
+}
+
+// Search looks through every channel in the archive for messages that contain
+// all of the words in query, best matches first. only the messages that the
+// index says have every word in them are read
+func (a *Archive) Search(query string) ([]SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil, errors.New("search for something")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.off() {
+		return nil, errArchiveOff
+	}
+	paths, err := filepath.Glob(filepath.Join(a.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	phrase := strings.Join(terms, " ")
+	results := make([]SearchResult, 0)
+	for _, path := range paths {
+		idx, err := a.index(path)
+		if err != nil {
+			return nil, err
+		}
+		lines := idx.lookup(terms)
+		if len(lines) == 0 {
+			continue
+		}
+		err = idx.read(path, lines, func(line int, am *ArchivedMessage) {
+			if s := score(am, terms, phrase); s > 0 {
+				results = append(results, SearchResult{am, s, path, line})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return b.Message.Time.Compare(a.Message.Time)
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+	return results, nil
+}
+
+// score ranks a message against the search terms, or returns 0 if any of the
+// terms is missing from it. whole words beat substrings, names beat bodies,
+// the whole query as a phrase beats its words scattered about, and shorter
+// messages beat longer ones with the same hits
+func score(am *ArchivedMessage, terms []string, phrase string) float64 {
+	body := strings.ToLower(am.Body)
+	words := strings.FieldsFunc(body, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	var name string
+	if am.Nick != nil {
+		name = strings.ToLower(*am.Nick)
+	}
+	if am.Handle != nil {
+		name = name + " " + strings.ToLower(*am.Handle)
+	}
+	var s float64
+	for _, t := range terms {
+		hits := strings.Count(body, t)
+		inname := strings.Contains(name, t)
+		if hits == 0 && !inname {
+			return 0
+		}
+		s += float64(min(hits, 5))
+		if slices.Contains(words, t) {
+			s += 2
+		}
+		if inname {
+			s += 3
+		}
+	}
+	if len(terms) > 1 && strings.Contains(body, phrase) {
+		s += 5
+	}
+	return s / (1 + float64(len(words))/50)
+}
+
+// around returns the messages either side of r in its channel, and where r
+// is among them
+func (a *Archive) around(r SearchResult) ([]*ArchivedMessage, int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.off() {
+		return nil, 0, errArchiveOff
+	}
+	idx, err := a.index(r.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	lines := make([]uint32, 0, 2*historyRadius+1)
+	for line := max(0, r.line-historyRadius); line <= r.line+historyRadius && line < len(idx.Offsets); line++ {
+		lines = append(lines, uint32(line))
+	}
+	msgs := make([]*ArchivedMessage, 0, len(lines))
+	at := 0
+	err = idx.read(r.path, lines, func(line int, am *ArchivedMessage) {
+		if line == r.line {
+			at = len(msgs)
+		}
+		msgs = append(msgs, am)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return msgs, at, nil
+}
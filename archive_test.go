@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func bodies(results []SearchResult) []string {
+	out := make([]string, 0, len(results))
+	for _, r := range results {
+		out = append(out, r.Message.Body)
+	}
+	return out
+}
+
+func TestArchiveSearch(t *testing.T) {
+	dir := t.TempDir()
+	a := openArchiveIn(dir)
+	nick := "ann"
+	for i, body := range []string{"hello world", "goodbye world", "héllo wörld", "nothing here"} {
+		err := a.add(&ArchivedMessage{Channel: "ws://a", LrcID: uint32(i), Nick: &nick, Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := a.Search("world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bodies(results), []string{"hello world", "goodbye world"}; !slices.Equal(got, want) {
+		t.Errorf("world got %q, want %q", got, want)
+	}
+	results, _ = a.Search("WÖRLD hé")
+	if got, want := bodies(results), []string{"héllo wörld"}; !slices.Equal(got, want) {
+		t.Errorf("wörld hé got %q, want %q", got, want)
+	}
+	// the nick is indexed as well as the body
+	results, _ = a.Search("ann here")
+	if got, want := bodies(results), []string{"nothing here"}; !slices.Equal(got, want) {
+		t.Errorf("ann here got %q, want %q", got, want)
+	}
+
+	// messages added after a search are found by the next one, by this
+	// archive and by one that loads the saved index
+	a.add(&ArchivedMessage{Channel: "ws://b", Body: "world peace"})
+	a.add(&ArchivedMessage{Channel: "ws://a", Body: "a whole new world"})
+	for _, a := range []*Archive{a, openArchiveIn(dir)} {
+		results, _ = a.Search("world")
+		if len(results) != 4 {
+			t.Errorf("world got %q, want 4 results", bodies(results))
+		}
+	}
+	results, _ = openArchiveIn(dir).Search("peace")
+	if got, want := bodies(results), []string{"world peace"}; !slices.Equal(got, want) {
+		t.Errorf("peace got %q, want %q", got, want)
+	}
+}
+
+func TestArchiveAround(t *testing.T) {
+	a := openArchiveIn(t.TempDir())
+	for i := range 2*historyRadius + 50 {
+		a.add(&ArchivedMessage{Channel: "ws://a", LrcID: uint32(i), Body: fmt.Sprintf("message %d", i)})
+	}
+	results, err := a.Search("message 7")
+	if err != nil || len(results) == 0 {
+		t.Fatal(results, err)
+	}
+	var r SearchResult
+	for _, r = range results {
+		if r.Message.Body == "message 7" {
+			break
+		}
+	}
+	msgs, at, err := a.around(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 7+historyRadius+1 || msgs[at].Body != "message 7" || msgs[0].LrcID != 0 {
+		t.Errorf("got %d messages with the result at %d, %q", len(msgs), at, msgs[at].Body)
+	}
+}
+
+// TestArchiveOff checks that an archive with nowhere to live doesn't go
+// looking for channels in the working directory
+func TestArchiveOff(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	err := os.WriteFile("stray.jsonl", []byte(`{"channel":"ws://a","body":"hello"}`+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Archive{}
+	if _, err := a.Search("hello"); !errors.Is(err, errArchiveOff) {
+		t.Errorf("search got %v, want %v", err, errArchiveOff)
+	}
+	if _, _, err := a.around(SearchResult{path: "stray.jsonl"}); !errors.Is(err, errArchiveOff) {
+		t.Errorf("around got %v, want %v", err, errArchiveOff)
+	}
+	if err := a.add(&ArchivedMessage{Channel: "ws://a", Body: "hi"}); err != nil {
+		t.Errorf("add got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("working directory has %d files in it, want only stray.jsonl", len(entries))
+	}
+
+	// a write that fails turns the archive off too
+	a = openArchiveIn(filepath.Join(dir, "stray.jsonl"))
+	if err := a.add(&ArchivedMessage{Channel: "ws://a", Body: "hi"}); err == nil {
+		t.Fatal("adding to an archive under a file worked")
+	}
+	if _, err := a.Search("hi"); !errors.Is(err, errArchiveOff) {
+		t.Errorf("search after a failed write got %v, want %v", err, errArchiveOff)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// archiveIndexVersion is bumped whenever what goes into an index changes, so
+// that old ones are rebuilt rather than trusted
+const archiveIndexVersion = 1
+
+// maxGram is the longest run of runes that is indexed. search terms up to
+// this long are looked up as they are, longer ones by every gram in them
+const maxGram = 3
+
+// archiveIndex is an inverted index over one channel's archive file. it maps
+// every run of up to maxGram runes in a message's lowercased body, nick and
+// handle to the lines that have it, so that a search only reads the lines
+// that can match. it is kept next to the file and only ever grows by reading
+// what was appended since Size
+type archiveIndex struct {
+	Version int
+	// Size is how much of the file has been indexed
+	Size int64
+	// Offsets is where each line of the file starts
+	Offsets []int64
+	Grams   map[string][]uint32
+}
+
+func indexPath(path string) string {
+	return strings.TrimSuffix(path, ".jsonl") + ".idx"
+}
+
+// loadArchiveIndex reads the index for the file at path, starting a new one
+// if there isn't a usable one
+func loadArchiveIndex(path string) *archiveIndex {
+	idx := &archiveIndex{}
+	f, err := os.Open(indexPath(path))
+	if err == nil {
+		err = gob.NewDecoder(bufio.NewReader(f)).Decode(idx)
+		f.Close()
+	}
+	fi, serr := os.Stat(path)
+	// an index for a file that has since shrunk is for some other file
+	if err != nil || idx.Version != archiveIndexVersion || serr != nil || fi.Size() < idx.Size {
+		idx = &archiveIndex{}
+	}
+	idx.Version = archiveIndexVersion
+	if idx.Grams == nil {
+		idx.Grams = make(map[string][]uint32)
+	}
+	return idx
+}
+
+func (idx *archiveIndex) save(path string) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(idx)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, buf.Bytes(), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// catchUp indexes the lines that were appended to the file at path since it
+// was last indexed, and reports whether there were any. a line that is still
+// being written is left for next time
+func (idx *archiveIndex) catchUp(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = f.Seek(idx.Size, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+	r := bufio.NewReader(f)
+	grew := false
+	for {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return grew, nil
+		}
+		if err != nil {
+			return grew, err
+		}
+		line := uint32(len(idx.Offsets))
+		idx.Offsets = append(idx.Offsets, idx.Size)
+		idx.Size += int64(len(data))
+		grew = true
+		var am ArchivedMessage
+		if json.Unmarshal(data, &am) != nil {
+			continue
+		}
+		idx.add(line, strings.ToLower(am.Body))
+		if am.Nick != nil {
+			idx.add(line, strings.ToLower(*am.Nick))
+		}
+		if am.Handle != nil {
+			idx.add(line, strings.ToLower(*am.Handle))
+		}
+	}
+}
+
+// add records that line has every gram of text
+func (idx *archiveIndex) add(line uint32, text string) {
+	runes := []rune(text)
+	for i := range runes {
+		for n := 1; n <= maxGram && i+n <= len(runes); n++ {
+			g := string(runes[i : i+n])
+			lines := idx.Grams[g]
+			// lines are indexed in order, so a line that already has the
+			// gram is always the last one
+			if len(lines) > 0 && lines[len(lines)-1] == line {
+				continue
+			}
+			idx.Grams[g] = append(lines, line)
+		}
+	}
+}
+
+// lookup returns the lines, in order, that have every gram of every term.
+// they might still not match, a term's grams can be spread about the message
+func (idx *archiveIndex) lookup(terms []string) []uint32 {
+	var lines []uint32
+	first := true
+	for _, t := range terms {
+		runes := []rune(t)
+		grams := []string{t}
+		if len(runes) > maxGram {
+			grams = grams[:0]
+			for i := 0; i+maxGram <= len(runes); i++ {
+				grams = append(grams, string(runes[i:i+maxGram]))
+			}
+		}
+		for _, g := range grams {
+			if first {
+				lines = slices.Clone(idx.Grams[g])
+				first = false
+			} else {
+				lines = intersect(lines, idx.Grams[g])
+			}
+			if len(lines) == 0 {
+				return nil
+			}
+		}
+	}
+	return lines
+}
+
+// intersect keeps the lines of a that are also in b, both are in order
+func intersect(a []uint32, b []uint32) []uint32 {
+	out := a[:0]
+	j := 0
+	for _, line := range a {
+		for j < len(b) && b[j] < line {
+			j++
+		}
+		if j < len(b) && b[j] == line {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// read calls fn with the message on each of lines, which are in order, from
+// the file at path. lines that don't decode are skipped
+func (idx *archiveIndex) read(path string, lines []uint32, fn func(line int, am *ArchivedMessage)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, line := range lines {
+		start := idx.Offsets[line]
+		end := idx.Size
+		if int(line)+1 < len(idx.Offsets) {
+			end = idx.Offsets[line+1]
+		}
+		data := make([]byte, end-start)
+		_, err := f.ReadAt(data, start)
+		if err != nil {
+			return err
+		}
+		var am ArchivedMessage
+		if json.Unmarshal(data, &am) != nil {
+			continue
+		}
+		fn(int(line), &am)
+	}
+	return nil
+}
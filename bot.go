@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/rachel-mp4/ttyxcvr/bot"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/xcvr"
+)
+
+// passwordEnv is where ttyxcvr bot looks for the app password to publish with
+const passwordEnv = "TTYXCVR_APP_PASSWORD"
+
+func botMain(args []string) int {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	join := fs.String("join", "", "channel to join, an at:// uri or an address like :dial takes")
+	fixture := fs.String("fixture", "", "answer linktitle from the pages in `file`, a json object of url to html, instead of fetching them")
+	handle := fs.String("handle", "", "publish what the bots say as records in this handle's repo, logging in with $"+passwordEnv)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr bot --join channel [flags] bot...")
+		fmt.Fprintln(fs.Output(), "bots: "+strings.Join(builtinNames(), ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *join == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	bots, err := loadBots(fs.Args(), cfg, *fixture)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	xc := xcvr.NewClient(xcvr.DefaultHost)
+	url, err := resolveJoin(ctx, xc, *join)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't join %s: %v\n", *join, err)
+		return 1
+	}
+	host := &bot.Host{URL: url, Log: logger}
+	if *handle != "" {
+		if !strings.HasPrefix(*join, "at://") {
+			fmt.Fprintln(os.Stderr, "can only publish in channels joined by at:// uri")
+			return 2
+		}
+		secret := os.Getenv(passwordEnv)
+		if secret == "" {
+			fmt.Fprintln(os.Stderr, "$"+passwordEnv+" isn't set")
+			return 2
+		}
+		host.Publisher, err = lrcclient.Login(ctx, *handle, secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't log in as %s: %v\n", *handle, err)
+			return 1
+		}
+		lexconn, err := xc.SubscribeLexStream(ctx, *join)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer lexconn.Close()
+		go func() {
+			err := readLexStream(lexconn, func(lsm *lex.SubscribeLexStream_Message) {
+				if lsm.SignetView != nil {
+					host.Signet(lsm.SignetView)
+				}
+			})
+			if ctx.Err() == nil {
+				logger.Printf("lex stream: %v", err)
+			}
+		}()
+	}
+	err = host.Run(ctx, bots)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// loadBots makes the named builtin bots, with the nick and color the config
+// gives them
+func loadBots(names []string, cfg *Config, fixture string) ([]bot.Bot, error) {
+	var fetch func(context.Context, string) (io.ReadCloser, error)
+	if fixture != "" {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			return nil, err
+		}
+		var pages map[string]string
+		err = json.Unmarshal(data, &pages)
+		if err != nil {
+			return nil, errors.New("fixture is corrupt: " + err.Error())
+		}
+		fetch = bot.FetchFixture(pages)
+	}
+	bots := make([]bot.Bot, 0, len(names))
+	for _, name := range names {
+		mk, ok := bot.Builtins[name]
+		if !ok {
+			return nil, errors.New("no bot called " + name + ", try one of " + strings.Join(builtinNames(), ", "))
+		}
+		handler := mk()
+		if lt, ok := handler.(*bot.LinkTitle); ok {
+			lt.Fetch = fetch
+		}
+		color := uint32(33096)
+		b := bot.Bot{Name: name, Nick: name, Color: &color, Handler: handler}
+		if bc, ok := cfg.Bots[name]; ok {
+			if bc.Nick != nil {
+				b.Nick = *bc.Nick
+			}
+			if bc.Color != nil {
+				b.Color = bc.Color
+			}
+		}
+		bots = append(bots, b)
+	}
+	return bots, nil
+}
+
+func builtinNames() []string {
+	names := make([]string, 0, len(bot.Builtins))
+	for name := range bot.Builtins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,315 @@
+// Package bot hosts lrc bots. a bot is a Handler that gets called with the
+// messages in a channel and answers through a Replier, the Host gives each
+// bot its own connection so that every bot has its own nick and color
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// Handler is what a bot does
+type Handler interface {
+	// OnMessage is called with every message that someone else publishes,
+	// each call in a goroutine of its own
+	OnMessage(ctx context.Context, r Replier, msg lrcclient.Message) error
+	// OnTyping is called from the event loop whenever a message that
+	// someone else is typing changes, so it should return quickly
+	OnTyping(ctx context.Context, r Replier, msg lrcclient.Message) error
+}
+
+// IgnoreTyping can be embedded in handlers that only care about finished
+// messages
+type IgnoreTyping struct{}
+
+func (IgnoreTyping) OnTyping(context.Context, Replier, lrcclient.Message) error {
+	return nil
+}
+
+// Replier sends messages as a bot. a bot only ever sends one message at a
+// time, so replies wait for each other
+type Replier interface {
+	// Say sends text all at once
+	Say(text string) error
+	// Type types text out live, a rune at a time, and then publishes it. if
+	// ctx is done partway through, what has been typed so far is published
+	Type(ctx context.Context, text string) error
+}
+
+// Bot is a handler along with who it is in the channel
+type Bot struct {
+	Name    string
+	Nick    string
+	Color   *uint32
+	Handler Handler
+}
+
+// Host runs bots in a channel
+type Host struct {
+	// URL is the channel's lrc websocket, anything lrcclient.Connect takes
+	URL string
+	// Publisher, if set, publishes what the bots say as
+	// org.xcvr.lrc.message records once the channel has signed it. that only
+	// happens in channels with a lex stream to pass on to Signet
+	Publisher *lrcclient.PasswordClient
+	// TypingDelay is how long Type waits between runes
+	TypingDelay time.Duration
+	// Log gets a line for every error a handler returns, nil keeps quiet
+	Log *log.Logger
+
+	mu sync.Mutex
+	// nicks holds the nick of every bot, so that bots don't answer each
+	// other. ids can't tell, a bot can see another bot's pub before the
+	// other bot has seen its own init
+	nicks map[string]bool
+	// signets and said are matched up by lrc id to publish records, since
+	// the signet can arrive on either side of the pub. the lex stream has
+	// everyone's signets, so whatever isn't matched within halfWindow is
+	// forgotten
+	signets map[uint32]signet
+	said    map[uint32]said
+	// records are published one at a time by publishAll
+	records chan *lex.MessageRecord
+}
+
+type signet struct {
+	sv *lex.SignetView
+	at time.Time
+}
+
+type said struct {
+	text  string
+	nick  string
+	color *uint32
+	at    time.Time
+}
+
+const (
+	defaultTypingDelay = 40 * time.Millisecond
+	// halfWindow is how long a signet or a pub waits for the other half of a
+	// record before it is given up on
+	halfWindow = time.Minute
+	// publishQueue is how many records can wait to be published before more
+	// are dropped
+	publishQueue = 64
+)
+
+// Run connects every bot to the channel and runs them until ctx is done or
+// one of them is disconnected
+func (h *Host) Run(ctx context.Context, bots []Bot) error {
+	h.mu.Lock()
+	h.nicks = make(map[string]bool)
+	for _, b := range bots {
+		h.nicks[b.Nick] = true
+	}
+	if h.signets == nil {
+		h.signets = make(map[uint32]signet)
+	}
+	h.said = make(map[uint32]said)
+	h.records = make(chan *lex.MessageRecord, publishQueue)
+	h.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if h.Publisher != nil {
+		go h.publishAll(ctx)
+	}
+	errs := make(chan error, len(bots))
+	for _, b := range bots {
+		nick := b.Nick
+		c, err := lrcclient.Connect(ctx, h.URL, lrcclient.Config{Nick: &nick, Color: b.Color, Scrollback: 1})
+		if err != nil {
+			return errors.New("couldn't connect " + b.Name + ": " + err.Error())
+		}
+		defer c.Close()
+		go func() {
+			errs <- h.run(ctx, b, c)
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	}
+}
+
+// run passes the channel's events on to one bot
+func (h *Host) run(ctx context.Context, b Bot, c *lrcclient.Client) error {
+	r := &replier{c: c, delay: h.TypingDelay}
+	if r.delay == 0 {
+		r.delay = defaultTypingDelay
+	}
+	for ev := range c.Events() {
+		m := ev.Message
+		if m == nil {
+			continue
+		}
+		if m.Mine {
+			h.mine(b, ev)
+			continue
+		}
+		if h.hosted(m.Nick) {
+			continue
+		}
+		switch ev.Kind {
+		case lrcclient.KindEdit:
+			h.report(b, b.Handler.OnTyping(ctx, r, *m))
+		case lrcclient.KindPub:
+			if m.Text == "" {
+				continue
+			}
+			go func() {
+				h.report(b, b.Handler.OnMessage(ctx, r, *m))
+			}()
+		}
+	}
+	if err := c.Err(); !errors.Is(err, lrcclient.ErrClosed) {
+		return errors.New(b.Name + " was disconnected: " + err.Error())
+	}
+	return nil
+}
+
+func (h *Host) report(b Bot, err error) {
+	if err != nil && h.Log != nil {
+		h.Log.Printf("%s: %v", b.Name, err)
+	}
+}
+
+// hosted reports whether nick is one of our bots
+func (h *Host) hosted(nick *string) bool {
+	if nick == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.nicks[*nick]
+}
+
+// mine keeps track of a message one of the bots has sent, to publish it
+func (h *Host) mine(b Bot, ev lrcclient.Event) {
+	m := ev.Message
+	if ev.Kind != lrcclient.KindPub || h.Publisher == nil || m.Text == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.said[m.ID] = said{m.Text, b.Nick, b.Color, time.Now()}
+	h.publish(m.ID)
+	h.prune()
+}
+
+// Signet tells the host about a signet from the channel's lex stream
+func (h *Host) Signet(sv *lex.SignetView) {
+	if h.Publisher == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// the lex stream can start before Run
+	if h.signets == nil {
+		h.signets = make(map[uint32]signet)
+	}
+	id := uint32(sv.LrcID)
+	h.signets[id] = signet{sv, time.Now()}
+	h.publish(id)
+	h.prune()
+}
+
+// prune forgets the signets and pubs that have waited longer than halfWindow
+// for each other, most signets are for other people's messages and never
+// will be matched. h.mu must be held
+func (h *Host) prune() {
+	cutoff := time.Now().Add(-halfWindow)
+	for id, s := range h.signets {
+		if s.at.Before(cutoff) {
+			delete(h.signets, id)
+		}
+	}
+	for id, s := range h.said {
+		if s.at.Before(cutoff) {
+			delete(h.said, id)
+		}
+	}
+}
+
+// publish queues the record for a message once we have both what was said
+// and the signet for it. h.mu must be held
+func (h *Host) publish(id uint32) {
+	sv, s := h.signets[id].sv, h.said[id]
+	if sv == nil || s.text == "" || h.records == nil {
+		return
+	}
+	delete(h.signets, id)
+	delete(h.said, id)
+	var color *uint64
+	if s.color != nil {
+		c := uint64(*s.color)
+		color = &c
+	}
+	record := &lex.MessageRecord{
+		SignetURI: sv.URI,
+		Body:      s.text,
+		Nick:      &s.nick,
+		Color:     color,
+		PostedAt:  syntax.DatetimeNow().String(),
+	}
+	select {
+	case h.records <- record:
+	default:
+		if h.Log != nil {
+			h.Log.Printf("couldn't publish %q: too many records are waiting", s.text)
+		}
+	}
+}
+
+// publishAll publishes queued records one after another until ctx is done
+func (h *Host) publishAll(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case record := <-h.records:
+			_, _, err := h.Publisher.CreateXCVRMessage(record, ctx)
+			if err != nil && h.Log != nil {
+				h.Log.Printf("couldn't publish %q: %v", record.Body, err)
+			}
+		}
+	}
+}
+
+type replier struct {
+	mu    sync.Mutex
+	c     *lrcclient.Client
+	delay time.Duration
+}
+
+func (r *replier) Say(text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c.Send(text)
+}
+
+func (r *replier) Type(ctx context.Context, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runes := []rune(text)
+	for i := range runes {
+		err := r.c.Edit(string(runes[:i+1]))
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return r.c.Publish()
+		case <-time.After(r.delay):
+		}
+	}
+	return r.c.Publish()
+}
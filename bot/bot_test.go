@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/relay"
+)
+
+// nag answers every message with an !echo, so it and echo would go back and
+// forth forever if they answered each other
+type nag struct {
+	IgnoreTyping
+}
+
+func (nag) OnMessage(ctx context.Context, r Replier, msg lrcclient.Message) error {
+	return r.Say("!echo again")
+}
+
+func TestHostBotsIgnoreEachOther(t *testing.T) {
+	srv := httptest.NewServer(relay.New("t"))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := &Host{URL: url, TypingDelay: time.Millisecond}
+	go h.Run(ctx, []Bot{
+		{Name: "echo", Nick: "echo", Handler: Echo{}},
+		{Name: "nag", Nick: "nag", Handler: nag{}},
+	})
+
+	nick := "ann"
+	c, err := lrcclient.Connect(ctx, url, lrcclient.Config{Nick: &nick})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// give the bots time to connect before saying anything
+	time.Sleep(100 * time.Millisecond)
+	c.Send("!echo hi")
+
+	said := map[string]int{}
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case ev := <-c.Events():
+			if ev.Kind == lrcclient.KindPub && ev.Message != nil && !ev.Message.Mine {
+				said[*ev.Message.Nick+": "+ev.Message.Text]++
+			}
+		case <-timeout:
+			if said["echo: hi"] != 1 || said["nag: !echo again"] != 1 || len(said) != 2 {
+				t.Errorf("got %v, want echo to say hi and nag to nag once", said)
+			}
+			return
+		}
+	}
+}
+
+// pds is a fake pds that keeps the body of every record it is given, and how
+// many it was given at once
+type pds struct {
+	mu       sync.Mutex
+	inflight int
+	most     int
+	bodies   []string
+}
+
+func (p *pds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/xrpc/com.atproto.repo.createRecord" {
+		var in struct {
+			Record lex.MessageRecord `json:"record"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		p.mu.Lock()
+		p.inflight++
+		p.most = max(p.most, p.inflight)
+		p.mu.Unlock()
+		// long enough for another publish to overlap this one, if it could
+		time.Sleep(10 * time.Millisecond)
+		p.mu.Lock()
+		p.inflight--
+		p.bodies = append(p.bodies, in.Record.Body)
+		n := len(p.bodies)
+		p.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"uri": fmt.Sprintf("at://did:plc:bot/org.xcvr.lrc.message/%d", n), "cid": "cid"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"accessJwt":  "access",
+		"refreshJwt": "refresh",
+		"handle":     "bot.test",
+		"did":        "did:plc:bot",
+	})
+}
+
+func (p *pds) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.bodies)
+}
+
+// TestHostPublishes has echo answer a few messages at once and checks that
+// each answer is published once its signet shows up, one at a time, and that
+// the signets of everyone else's messages don't pile up
+func TestHostPublishes(t *testing.T) {
+	s := relay.New("t")
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	p := &pds{}
+	pdssrv := httptest.NewServer(p)
+	defer pdssrv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pc := lrcclient.NewPasswordClient("did:plc:bot", pdssrv.URL)
+	err := pc.CreateSession(ctx, "bot.test", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Host{URL: url, Publisher: pc, TypingDelay: time.Millisecond}
+	go h.Run(ctx, []Bot{{Name: "echo", Nick: "echo", Handler: Echo{}}})
+
+	nick := "ann"
+	c, err := lrcclient.Connect(ctx, url, lrcclient.Config{Nick: &nick})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Connected() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("echo never connected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	want := []string{"a", "b", "c", "d"}
+	for _, text := range want {
+		c.Send("!echo " + text)
+	}
+
+	// the lex stream has a signet for every message, whoever's it is
+	timeout := time.After(5 * time.Second)
+	for len(p.published()) < len(want) {
+		select {
+		case ev := <-c.Events():
+			if ev.Kind == lrcclient.KindInit {
+				id := ev.Message.ID
+				h.Signet(&lex.SignetView{URI: fmt.Sprintf("at://did:plc:host/org.xcvr.lrc.signet/%d", id), LrcID: uint64(id)})
+			}
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("published %v, want %v", p.published(), want)
+		}
+	}
+	got := p.published()
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+	if p.most != 1 {
+		t.Errorf("%d records were published at once, want one at a time", p.most)
+	}
+
+	// ann's signets are waiting for pubs that won't come, until they are
+	// too old to wait any longer
+	h.mu.Lock()
+	if len(h.signets) != len(want) {
+		t.Errorf("holding %d signets, want ann's %d", len(h.signets), len(want))
+	}
+	for id, s := range h.signets {
+		s.at = s.at.Add(-halfWindow)
+		h.signets[id] = s
+	}
+	h.mu.Unlock()
+	h.Signet(&lex.SignetView{URI: "at://did:plc:host/org.xcvr.lrc.signet/99", LrcID: 99})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.signets) != 1 {
+		t.Errorf("holding %d signets, want just the new one", len(h.signets))
+	}
+}
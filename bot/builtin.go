@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// Builtins are the bots that come with ttyxcvr, by name
+var Builtins = map[string]func() Handler{
+	"echo":      func() Handler { return Echo{} },
+	"dice":      func() Handler { return Dice{} },
+	"linktitle": func() Handler { return &LinkTitle{} },
+}
+
+// command splits "!name rest" into rest, if msg is that command
+func command(msg lrcclient.Message, name string) (string, bool) {
+	text := strings.TrimSpace(msg.Text)
+	cmd, rest, _ := strings.Cut(text, " ")
+	if cmd != "!"+name {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// Echo types back whatever follows !echo
+type Echo struct {
+	IgnoreTyping
+}
+
+func (Echo) OnMessage(ctx context.Context, r Replier, msg lrcclient.Message) error {
+	text, ok := command(msg, "echo")
+	if !ok || text == "" {
+		return nil
+	}
+	return r.Type(ctx, text)
+}
+
+// Dice answers !roll NdM+K, rolling 1d6 if there's nothing after it
+type Dice struct {
+	IgnoreTyping
+}
+
+const (
+	maxDice  = 100
+	maxSides = 1000
+)
+
+var diceRe = regexp.MustCompile(`^(\d*)d(\d+)([+-]\d+)?$`)
+
+func (Dice) OnMessage(ctx context.Context, r Replier, msg lrcclient.Message) error {
+	spec, ok := command(msg, "roll")
+	if !ok {
+		return nil
+	}
+	if spec == "" {
+		spec = "1d6"
+	}
+	n, sides, mod, err := parseDice(spec)
+	if err != nil {
+		return r.Say(err.Error())
+	}
+	total := mod
+	rolls := make([]string, n)
+	for i := range n {
+		roll := rand.IntN(sides) + 1
+		total += roll
+		rolls[i] = strconv.Itoa(roll)
+	}
+	out := fmt.Sprintf("%s: %s", spec, strings.Join(rolls, " + "))
+	if mod != 0 {
+		out += fmt.Sprintf(" (%+d)", mod)
+	}
+	if n > 1 || mod != 0 {
+		out += fmt.Sprintf(" = %d", total)
+	}
+	return r.Say(out)
+}
+
+func parseDice(spec string) (n int, sides int, mod int, err error) {
+	m := diceRe.FindStringSubmatch(strings.ToLower(spec))
+	if m == nil {
+		return 0, 0, 0, errors.New("can't roll " + spec + ", try something like 2d6+1")
+	}
+	n = 1
+	if m[1] != "" {
+		n, _ = strconv.Atoi(m[1])
+	}
+	sides, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		mod, _ = strconv.Atoi(m[3])
+	}
+	if n < 1 || n > maxDice {
+		return 0, 0, 0, errors.New("can only roll 1 to " + strconv.Itoa(maxDice) + " dice")
+	}
+	if sides < 1 || sides > maxSides {
+		return 0, 0, 0, errors.New("dice can have 1 to " + strconv.Itoa(maxSides) + " sides")
+	}
+	return n, sides, mod, nil
+}
+
+// LinkTitle says the title of the first link in every message that has one
+type LinkTitle struct {
+	IgnoreTyping
+	// Fetch gets the page at url, FetchHTTP if nil
+	Fetch func(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+const (
+	fetchTimeout = 10 * time.Second
+	// maxPage is how much of a page is read looking for its title
+	maxPage = 512 * 1024
+)
+
+var (
+	linkRe  = regexp.MustCompile(`https?://[^\s<>"]+`)
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+func (lt *LinkTitle) OnMessage(ctx context.Context, r Replier, msg lrcclient.Message) error {
+	url := linkRe.FindString(msg.Text)
+	if url == "" {
+		return nil
+	}
+	fetch := lt.Fetch
+	if fetch == nil {
+		fetch = FetchHTTP
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	body, err := fetch(ctx, url)
+	if err != nil {
+		return errors.New("couldn't fetch " + url + ": " + err.Error())
+	}
+	defer body.Close()
+	page, err := io.ReadAll(io.LimitReader(body, maxPage))
+	if err != nil {
+		return errors.New("couldn't read " + url + ": " + err.Error())
+	}
+	title := pageTitle(string(page))
+	if title == "" {
+		return nil
+	}
+	return r.Say(title)
+}
+
+// pageTitle returns the contents of the page's <title> with the whitespace
+// collapsed
+func pageTitle(page string) string {
+	m := titleRe.FindStringSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+}
+
+// FetchHTTP gets url over http
+func FetchHTTP(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.New(res.Status)
+	}
+	return res.Body, nil
+}
+
+// FetchFixture serves pages from pages, keyed by url, instead of going out
+// to the network
+func FetchFixture(pages map[string]string) func(context.Context, string) (io.ReadCloser, error) {
+	return func(ctx context.Context, url string) (io.ReadCloser, error) {
+		page, ok := pages[url]
+		if !ok {
+			return nil, errors.New("no fixture for " + url)
+		}
+		return io.NopCloser(strings.NewReader(page)), nil
+	}
+}
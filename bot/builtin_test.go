@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// recorder is a Replier that keeps what it was told to say
+type recorder struct {
+	said []string
+}
+
+func (r *recorder) Say(text string) error {
+	r.said = append(r.said, text)
+	return nil
+}
+
+func (r *recorder) Type(ctx context.Context, text string) error {
+	return r.Say(text)
+}
+
+func TestParseDice(t *testing.T) {
+	tests := []struct {
+		spec          string
+		n, sides, mod int
+		err           bool
+	}{
+		{"1d6", 1, 6, 0, false},
+		{"d20", 1, 20, 0, false},
+		{"2d6+1", 2, 6, 1, false},
+		{"3D8-2", 3, 8, -2, false},
+		{"100d1000", 100, 1000, 0, false},
+		{"0d6", 0, 0, 0, true},
+		{"101d6", 0, 0, 0, true},
+		{"1d0", 0, 0, 0, true},
+		{"1d1001", 0, 0, 0, true},
+		{"2d", 0, 0, 0, true},
+		{"six", 0, 0, 0, true},
+		{"1d6+", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		n, sides, mod, err := parseDice(tt.spec)
+		if (err != nil) != tt.err || n != tt.n || sides != tt.sides || mod != tt.mod {
+			t.Errorf("%s got %d, %d, %d, %v", tt.spec, n, sides, mod, err)
+		}
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"<html><head><title>hello</title></head></html>", "hello"},
+		{"<TITLE lang=en>\n  spread \t out\n</TITLE>", "spread out"},
+		{"<title>fish &amp; chips &#x1F41F;</title>", "fish & chips 🐟"},
+		{"<title></title>", ""},
+		{"<h1>no title</h1>", ""},
+		{"<title>first</title><title>second</title>", "first"},
+	}
+	for _, tt := range tests {
+		if got := pageTitle(tt.page); got != tt.want {
+			t.Errorf("%q got %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestLinkTitle(t *testing.T) {
+	lt := &LinkTitle{Fetch: FetchFixture(map[string]string{
+		"https://example.com/a": "<title>page a</title>",
+		"http://example.com/b":  "<p>untitled</p>",
+	})}
+	tests := []struct {
+		text string
+		said []string
+		err  bool
+	}{
+		{"look https://example.com/a and http://example.com/b", []string{"page a"}, false},
+		{"http://example.com/b", nil, false},
+		{"no links here", nil, false},
+		{"https://example.com/missing", nil, true},
+	}
+	for _, tt := range tests {
+		r := &recorder{}
+		err := lt.OnMessage(context.Background(), r, lrcclient.Message{Text: tt.text})
+		if (err != nil) != tt.err || !slices.Equal(r.said, tt.said) {
+			t.Errorf("%q said %q with error %v", tt.text, r.said, err)
+		}
+	}
+}
+
+func TestDice(t *testing.T) {
+	tests := []struct {
+		text   string
+		prefix string
+	}{
+		{"!roll", "1d6: "},
+		{"!roll 2d6+1", "2d6+1: "},
+		{"!roll lots", "can't roll lots"},
+		{"roll 2d6", ""},
+	}
+	for _, tt := range tests {
+		r := &recorder{}
+		Dice{}.OnMessage(context.Background(), r, lrcclient.Message{Text: tt.text})
+		if tt.prefix == "" {
+			if len(r.said) != 0 {
+				t.Errorf("%q said %q", tt.text, r.said)
+			}
+			continue
+		}
+		if len(r.said) != 1 || !strings.HasPrefix(r.said[0], tt.prefix) {
+			t.Errorf("%q said %q, want %q...", tt.text, r.said, tt.prefix)
+		}
+	}
+}
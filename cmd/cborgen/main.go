@@ -0,0 +1,31 @@
+// cborgen writes the cbor marshalling for every type that lexgen decided can
+// end up in a repo. it has to be built with -tags lexgen so that the lex
+// package still compiles while its cbor methods are missing or stale
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func main() {
+	out := flag.String("out", "lex/lexicons_cbor.go", "file to write the cbor marshalling to")
+	flag.Parse()
+
+	gen := cbg.Gen{MaxStringLength: 1_000_000}
+	err := gen.WriteMapEncodersToFile(*out, "lex", cborTypes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.WriteFile(*out, append([]byte("//go:build !lexgen\n\n"), data...), 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultScrollback = 1000
+
+// Config is read from config.json in the user config dir. every field is
+// optional, anything that is left out keeps its default
+type Config struct {
+	// Scrollback is how many messages of a channel are kept in memory, older
+	// ones are spilled to disk and paged back in when you scroll up to them.
+	// 0 keeps everything in memory. the spill is a temp file that is removed
+	// when you leave the channel, so scrollback doesn't outlive the session,
+	// the archive is what does
+	Scrollback *int `json:"scrollback,omitempty"`
+	// Private keeps drafts to ourselves until enter sends them whole,
+	// instead of every keystroke going out as it is typed
+	Private *bool `json:"private,omitempty"`
+	// EditWindow is how long what we type is held before it is sent, like
+	// "50ms", so that everything typed in that time goes out as one edit
+	EditWindow *string `json:"editWindow,omitempty"`
+	// Bots sets who each bot run by ttyxcvr bot is in the channel, by the
+	// bot's name
+	Bots map[string]BotConfig `json:"bots,omitempty"`
+	// Hooks runs commands when things happen in the channel we are in
+	Hooks *HooksConfig `json:"hooks,omitempty"`
+	// SSH is who can use ttyxcvr ssh-serve, by the SHA256 fingerprint of
+	// their key as ssh-keygen -lf prints it
+	SSH map[string]SSHUser `json:"ssh,omitempty"`
+}
+
+// SSHUser is who someone is when they come in over ssh. since the app
+// password is kept here, the config should only be readable by whoever runs
+// the server
+type SSHUser struct {
+	// Name is what their outbox and archive are kept under, people with
+	// several keys can share them by giving each the same name. it defaults
+	// to the fingerprint
+	Name        *string `json:"name,omitempty"`
+	Nick        *string `json:"nick,omitempty"`
+	Color       *uint32 `json:"color,omitempty"`
+	Handle      *string `json:"handle,omitempty"`
+	AppPassword *string `json:"appPassword,omitempty"`
+}
+
+// BotConfig is who a bot is in the channel. a bot goes by its name with the
+// default color if it isn't configured
+type BotConfig struct {
+	Nick  *string `json:"nick,omitempty"`
+	Color *uint32 `json:"color,omitempty"`
+}
+
+func configDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "ttyxcvr"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ttyxcvr"), nil
+}
+
+func loadConfig() (*Config, error) {
+	c := &Config{}
+	dir, err := configDir()
+	if err != nil {
+		return c, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return &Config{}, errors.New("config is corrupt: " + err.Error())
+	}
+	return c, nil
+}
+
+// apply copies everything that was set in the config onto the global settings
+func (c *Config) apply(gsd *globalsettingsdata) error {
+	gsd.scrollback = defaultScrollback
+	if c.Scrollback != nil && *c.Scrollback >= 0 {
+		gsd.scrollback = *c.Scrollback
+	}
+	if c.Private != nil {
+		gsd.private = *c.Private
+	}
+	var werr error
+	if c.EditWindow != nil {
+		d, err := time.ParseDuration(*c.EditWindow)
+		if err == nil && d >= 0 {
+			gsd.editwindow = d
+		} else {
+			werr = errors.New("edit window " + *c.EditWindow + " isn't a duration like 50ms")
+		}
+	}
+	hooks, err := newHooks(c.Hooks, gsd.send)
+	if err != nil {
+		return errors.Join(werr, errors.New("hooks are off: "+err.Error()))
+	}
+	gsd.hooks = hooks
+	return werr
+}
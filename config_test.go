@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigApply(t *testing.T) {
+	window := "75ms"
+	var gsd globalsettingsdata
+	err := (&Config{EditWindow: &window}).apply(&gsd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gsd.editwindow != 75*time.Millisecond {
+		t.Errorf("edit window is %v, want 75ms", gsd.editwindow)
+	}
+
+	// a bad edit window doesn't hide bad hooks, or the other way round
+	bad := "soon"
+	c := &Config{EditWindow: &bad, Hooks: &HooksConfig{On: map[string][]string{"nope": {"true"}}}}
+	err = c.apply(&gsd)
+	if err == nil {
+		t.Fatal("no error for a bad edit window and bad hooks")
+	}
+	for _, want := range []string{"edit window soon", "hooks are off"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+	if gsd.editwindow != 75*time.Millisecond {
+		t.Errorf("bad edit window changed it to %v", gsd.editwindow)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+// messageJSON is a Message with its fields out in the open, it is what the
+// jsonl export writes and what the scrollback spills to disk
+type messageJSON struct {
+	ID     uint32          `json:"id"`
+	Nick   *string         `json:"nick,omitempty"`
+	Handle *string         `json:"handle,omitempty"`
+	Color  *uint32         `json:"color,omitempty"`
+	Active bool            `json:"active,omitempty"`
+	Text   string          `json:"text"`
+	Signet *lex.SignetView `json:"signet,omitempty"`
+	URI    *string         `json:"uri,omitempty"`
+	CID    *string         `json:"cid,omitempty"`
+	Unsent bool            `json:"unsent,omitempty"`
+	Outbox *OutboxEntry    `json:"outbox,omitempty"`
+}
+
+func (m *Message) json() messageJSON {
+	return messageJSON{
+		ID:     m.id,
+		Nick:   m.nick,
+		Handle: m.handle,
+		Color:  m.color,
+		Active: m.active,
+		Text:   m.text,
+		Signet: m.signet,
+		URI:    m.uri,
+		CID:    m.cid,
+		Unsent: m.unsent,
+		Outbox: m.outbox.snapshot(),
+	}
+}
+
+// message turns mj back into a Message, leaving out the outbox entry since
+// that only means anything while it is the one in the outbox
+func (mj messageJSON) message() *Message {
+	return &Message{
+		id:     mj.ID,
+		nick:   mj.Nick,
+		handle: mj.Handle,
+		color:  mj.Color,
+		active: mj.Active,
+		text:   mj.Text,
+		signet: mj.Signet,
+		uri:    mj.URI,
+		cid:    mj.CID,
+		unsent: mj.Unsent,
+	}
+}
+
+type exportMsg struct {
+	value []string
+}
+
+var exportExtensions = map[string]string{
+	"jsonl":    "jsonl",
+	"md":       "md",
+	"markdown": "md",
+	"html":     "html",
+}
+
+func (m model) export(args []string) (tea.Model, tea.Cmd) {
+	var out string
+	switch {
+	case m.cm == nil:
+		out = "join a channel before exporting it"
+	case len(args) == 0 || len(args) > 2 || exportExtensions[args[0]] == "":
+		out = "usage: :export jsonl|md|html [file]"
+	}
+	if out != "" {
+		m.cmdout = &out
+		return m, nil
+	}
+	ext := exportExtensions[args[0]]
+	path := fmt.Sprintf("%s-%s.%s", spillName(m.cm.title()), time.Now().Format("20060102-150405"), ext)
+	if len(args) == 2 {
+		path = args[1]
+	}
+	n, err := m.cm.export(path, ext)
+	if err != nil {
+		out = "couldn't export: " + err.Error()
+	} else {
+		out = fmt.Sprintf("exported %d messages to %s", n, path)
+	}
+	m.cmdout = &out
+	return m, nil
+}
+
+// title is what the channel is called in exports, its title if we came to it
+// from the channel list and its address if we dialed it
+func (cm *channelmodel) title() string {
+	if cm.channel != nil {
+		return cm.channel.Title
+	}
+	return cm.wsurl
+}
+
+// export writes every message in the channel, including the ones that have
+// been spilled to disk, to path in the given format
+func (cm *channelmodel) export(path string, format string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	var n int
+	switch format {
+	case "jsonl":
+		n, err = cm.exportJSONL(w)
+	case "md":
+		n, err = cm.exportMarkdown(w, time.Now())
+	case "html":
+		n, err = cm.exportHTML(w, time.Now())
+	default:
+		err = errors.New("unknown export format " + format)
+	}
+	err = errors.Join(err, w.Flush(), f.Close())
+	return n, err
+}
+
+// eachExported calls fn with every message that goes in an export, which is
+// all of them but the ones that were pub'd empty, and returns how many there
+// were
+func (cm *channelmodel) eachExported(fn func(*Message) error) (int, error) {
+	n := 0
+	err := cm.store.Each(func(m *Message) error {
+		if m.collapsed() {
+			return nil
+		}
+		n++
+		return fn(m)
+	})
+	return n, err
+}
+
+func (cm *channelmodel) exportJSONL(w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	return cm.eachExported(func(m *Message) error {
+		return enc.Encode(m.json())
+	})
+}
+
+// exportName is how the author of a message is written in the markdown and
+// html exports
+func (m *Message) exportName() (name string, note string) {
+	nick := "anon"
+	if m.nick != nil {
+		nick = *m.nick
+	}
+	switch {
+	case m.impersonating():
+		return nick, fmt.Sprintf("%s claims @%s but is signed as @%s", warning, *m.handle, m.signet.AuthorHandle)
+	case m.signet != nil:
+		return nick, "@" + m.signet.AuthorHandle
+	case m.handle != nil:
+		return nick, fmt.Sprintf("@%s %s", *m.handle, unverified)
+	}
+	return nick, unverified
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func (cm *channelmodel) exportMarkdown(w io.Writer, exported time.Time) (int, error) {
+	fmt.Fprintf(w, "# %s\n\n", markdownEscaper.Replace(cm.title()))
+	fmt.Fprintf(w, "lrc://%s, exported %s\n\n", markdownEscaper.Replace(cm.wsurl), exported.Format(time.RFC1123))
+	return cm.eachExported(func(m *Message) error {
+		nick, note := m.exportName()
+		fmt.Fprintf(w, "**%s** %s", markdownEscaper.Replace(nick), markdownEscaper.Replace(note))
+		if m.uri != nil {
+			fmt.Fprintf(w, " %s", persisted)
+		}
+		if m.active {
+			fmt.Fprintf(w, " *(typing)*")
+		}
+		fmt.Fprint(w, "\n\n")
+		for _, line := range strings.Split(m.text, "\n") {
+			fmt.Fprintf(w, "> %s\n", markdownEscaper.Replace(line))
+		}
+		_, err := fmt.Fprint(w, "\n")
+		return err
+	})
+}
+
+const htmlHead = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { background: #000000; color: #ffffff; font-family: monospace; max-width: 80ch; margin: 2em auto; }
+header { color: #5c5c5c; margin-bottom: 2em; }
+.message { margin-bottom: 1em; }
+.name { font-weight: bold; }
+.note { color: #5c5c5c; }
+.warning { background: #d7263d; color: #ffffff; font-weight: bold; }
+.active { font-style: italic; }
+.text { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<header><h1>%s</h1>lrc://%s, exported %s</header>
+`
+
+func (cm *channelmodel) exportHTML(w io.Writer, exported time.Time) (int, error) {
+	title := html.EscapeString(cm.title())
+	fmt.Fprintf(w, htmlHead, title, title, html.EscapeString(cm.wsurl), exported.Format(time.RFC1123))
+	n, err := cm.eachExported(func(m *Message) error {
+		nick, note := m.exportName()
+		class := "message"
+		if m.active {
+			class += " active"
+		}
+		noteclass := "note"
+		if m.impersonating() {
+			noteclass = "warning"
+		}
+		if m.uri != nil {
+			note = fmt.Sprintf("%s %s", note, persisted)
+		}
+		_, err := fmt.Fprintf(w, "<div class=\"%s\"><span class=\"name\" style=\"color: %s\">%s</span> <span class=\"%s\">%s</span><div class=\"text\">%s</div></div>\n",
+			class, string(ColorFromInt(m.color)), html.EscapeString(nick), noteclass, html.EscapeString(note), html.EscapeString(m.text))
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	_, err = fmt.Fprint(w, "</body>\n</html>\n")
+	return n, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// exportChannel has one of everything an export has to deal with: a signed
+// message with html and markdown in it, one that was pub'd empty, one whose
+// handle disagrees with its signet, and one still being typed
+func exportChannel(t *testing.T) *channelmodel {
+	f := newFeed()
+	f.init(t, 1, "ann")
+	f.insert(t, 1, 0, "<b>hi</b> & \"bye\"\nsecond *line* [x](y)")
+	f.pub(t, 1)
+	f.init(t, 2, "bob")
+	f.pub(t, 2)
+	f.init(t, 3, "cat")
+	f.insert(t, 3, 0, "i'm <dan>")
+	f.pub(t, 3)
+	f.init(t, 4, "eve")
+	f.insert(t, 4, 0, "typ")
+
+	ann := f.store.Get(1)
+	handle, uri := "ann.test", "at://did:plc:ann/org.xcvr.lrc.message/1"
+	ann.handle = &handle
+	ann.signet = &lex.SignetView{AuthorHandle: "ann.test"}
+	ann.uri = &uri
+	cat := f.store.Get(3)
+	claimed := "dan.test"
+	cat.handle = &claimed
+	cat.signet = &lex.SignetView{AuthorHandle: "cat.test"}
+	return &channelmodel{wsurl: "lrc.test/<lounge>", store: f.store}
+}
+
+func TestExport(t *testing.T) {
+	exported := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	formats := []struct {
+		name   string
+		export func(cm *channelmodel, buf *bytes.Buffer) (int, error)
+	}{
+		{"jsonl", func(cm *channelmodel, buf *bytes.Buffer) (int, error) { return cm.exportJSONL(buf) }},
+		{"md", func(cm *channelmodel, buf *bytes.Buffer) (int, error) { return cm.exportMarkdown(buf, exported) }},
+		{"html", func(cm *channelmodel, buf *bytes.Buffer) (int, error) { return cm.exportHTML(buf, exported) }},
+	}
+	for _, format := range formats {
+		var buf bytes.Buffer
+		n, err := format.export(exportChannel(t), &buf)
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		// bob's empty message is left out
+		if n != 3 {
+			t.Errorf("%s: exported %d messages, want 3", format.name, n)
+		}
+		golden := filepath.Join("testdata", "export."+format.name)
+		if *update {
+			err := os.WriteFile(golden, buf.Bytes(), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s export doesn't match %s, got:\n%s", format.name, golden, buf.String())
+		}
+	}
+}
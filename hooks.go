@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// the events that hooks can be run on
+const (
+	// hookPublish is any message being pub'd, ours included
+	hookPublish = "publish"
+	// hookMention is someone else pub'ing a message with our nick or handle
+	// in it
+	hookMention = "mention"
+	// hookJoin is someone starting a message for the first time since we
+	// connected, lrc has no other way of telling that someone is there
+	hookJoin       = "join"
+	hookConnect    = "connect"
+	hookDisconnect = "disconnect"
+)
+
+var hookEvents = []string{hookPublish, hookMention, hookJoin, hookConnect, hookDisconnect}
+
+const (
+	defaultHookTimeout     = 10 * time.Second
+	defaultHookConcurrency = 4
+	// hookQueue is how many commands can wait for their turn, past that
+	// they are dropped
+	hookQueue = 64
+	// hookExitWait is how long we wait on hooks that are still running when
+	// the program quits
+	hookExitWait = 2 * time.Second
+)
+
+// HooksConfig runs commands when things happen in a channel
+type HooksConfig struct {
+	// Timeout is how long a command gets before it is killed, like "10s"
+	Timeout *string `json:"timeout,omitempty"`
+	// Concurrency is how many commands can run at once, the rest wait their
+	// turn
+	Concurrency *int `json:"concurrency,omitempty"`
+	// On is the commands to run for each event, each one through sh -c
+	On map[string][]string `json:"on,omitempty"`
+}
+
+// Hooks runs the commands from the config on a fixed number of workers. it
+// is nil when there aren't any, which is safe to fire
+type Hooks struct {
+	send    func(tea.Msg)
+	on      map[string][]string
+	timeout time.Duration
+	jobs    chan hookJob
+	wg      sync.WaitGroup
+}
+
+type hookJob struct {
+	event   string
+	command string
+	payload []byte
+	env     []string
+}
+
+var errHookQueueFull = errors.New("dropped, too many hooks are waiting to run")
+
+// hookEvent is what a hook command gets on stdin
+type hookEvent struct {
+	Event   string       `json:"event"`
+	Time    time.Time    `json:"time"`
+	Channel hookChannel  `json:"channel"`
+	Message *messageJSON `json:"message,omitempty"`
+	Mine    bool         `json:"mine,omitempty"`
+	// Error is why we were disconnected, if it wasn't us leaving
+	Error string `json:"error,omitempty"`
+}
+
+type hookChannel struct {
+	URL   string  `json:"url"`
+	URI   *string `json:"uri,omitempty"`
+	Title *string `json:"title,omitempty"`
+}
+
+type hookFailedMsg struct {
+	event   string
+	command string
+	err     error
+}
+
+func newHooks(hc *HooksConfig, send func(tea.Msg)) (*Hooks, error) {
+	if hc == nil || len(hc.On) == 0 {
+		return nil, nil
+	}
+	h := &Hooks{send: send, on: hc.On, timeout: defaultHookTimeout}
+	for event := range hc.On {
+		if !slices.Contains(hookEvents, event) {
+			return nil, errors.New("no hook event called " + event + ", try one of " + strings.Join(hookEvents, ", "))
+		}
+	}
+	if hc.Timeout != nil {
+		d, err := time.ParseDuration(*hc.Timeout)
+		if err != nil || d <= 0 {
+			return nil, errors.New("hook timeout " + *hc.Timeout + " isn't a duration like 10s")
+		}
+		h.timeout = d
+	}
+	n := defaultHookConcurrency
+	if hc.Concurrency != nil {
+		n = *hc.Concurrency
+		if n < 1 {
+			return nil, errors.New("hook concurrency has to be at least 1")
+		}
+	}
+	h.jobs = make(chan hookJob, hookQueue)
+	for range n {
+		go h.work()
+	}
+	return h, nil
+}
+
+// fire queues every command for the event to run in the background,
+// failures are sent to the program as hookFailedMsg. it never blocks, a
+// command that doesn't fit in the queue is dropped
+func (h *Hooks) fire(he *hookEvent) {
+	if h == nil || len(h.on[he.Event]) == 0 {
+		return
+	}
+	payload, err := json.Marshal(he)
+	if err != nil {
+		return
+	}
+	payload = append(payload, '\n')
+	env := he.env()
+	for _, command := range h.on[he.Event] {
+		h.wg.Add(1)
+		select {
+		case h.jobs <- hookJob{he.Event, command, payload, env}:
+		default:
+			h.wg.Done()
+			// fire is called from Update, which send would wait on
+			go h.send(hookFailedMsg{he.Event, command, errHookQueueFull})
+		}
+	}
+}
+
+// work runs queued commands one at a time for as long as the program runs
+func (h *Hooks) work() {
+	for job := range h.jobs {
+		err := h.run(job.command, job.payload, job.env)
+		if err != nil {
+			h.send(hookFailedMsg{job.event, job.command, err})
+		}
+		h.wg.Done()
+	}
+}
+
+func (h *Hooks) run(command string, payload []byte, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// a command that leaves something running in the background with our
+	// stderr could otherwise keep us waiting past the timeout
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return errors.New("timed out after " + h.timeout.String())
+	}
+	if err != nil {
+		if out := strings.TrimSpace(stderr.String()); out != "" {
+			return errors.New(err.Error() + ": " + out)
+		}
+		return err
+	}
+	return nil
+}
+
+// wait gives the hooks that are still running up to d to finish
+func (h *Hooks) wait(d time.Duration) {
+	if h == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+	}
+}
+
+// env is he as environment variables, for commands that would rather not
+// parse json. the ones that don't apply are left unset
+func (he *hookEvent) env() []string {
+	env := []string{
+		"TTYXCVR_EVENT=" + he.Event,
+		"TTYXCVR_CHANNEL_URL=" + he.Channel.URL,
+	}
+	add := func(key string, val *string) {
+		if val != nil {
+			env = append(env, key+"="+*val)
+		}
+	}
+	add("TTYXCVR_CHANNEL_URI", he.Channel.URI)
+	add("TTYXCVR_CHANNEL_TITLE", he.Channel.Title)
+	if he.Error != "" {
+		env = append(env, "TTYXCVR_ERROR="+he.Error)
+	}
+	if m := he.Message; m != nil {
+		env = append(env, "TTYXCVR_ID="+strconv.FormatUint(uint64(m.ID), 10), "TTYXCVR_TEXT="+m.Text)
+		add("TTYXCVR_NICK", m.Nick)
+		add("TTYXCVR_HANDLE", m.Handle)
+		if m.Color != nil {
+			env = append(env, "TTYXCVR_COLOR="+strconv.FormatUint(uint64(*m.Color), 10))
+		}
+		if he.Mine {
+			env = append(env, "TTYXCVR_MINE=1")
+		}
+	}
+	return env
+}
+
+// hook fires he in this channel, filling in which channel it is
+func (cm *channelmodel) hook(he *hookEvent) {
+	if cm.gsd.hooks == nil {
+		return
+	}
+	he.Time = time.Now()
+	he.Channel = hookChannel{URL: cm.wsurl}
+	if cm.channel != nil {
+		he.Channel.URI = &cm.channel.URI
+		he.Channel.Title = &cm.channel.Title
+	}
+	cm.gsd.hooks.fire(he)
+}
+
+// hookMessage is msg as hooks see it, with the signed handle if there is one
+func hookMessage(msg *Message) *messageJSON {
+	mj := msg.json()
+	mj.Outbox = nil
+	if msg.signet != nil {
+		mj.Handle = &msg.signet.AuthorHandle
+	}
+	return &mj
+}
+
+// mentions reports whether text has any of names in it as a word of its own,
+// with or without an @ in front
+func mentions(text string, names ...*string) bool {
+	for _, word := range strings.Fields(text) {
+		word = strings.TrimLeft(word, "@")
+		word = strings.TrimRight(word, ".,:;!?)'\"")
+		for _, name := range names {
+			if name != nil && *name != "" && strings.EqualFold(word, *name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// seen fires join the first time someone starts a message
+func (cm *channelmodel) seen(msg *Message) {
+	var who string
+	if msg.nick != nil {
+		who = *msg.nick
+	}
+	if msg.handle != nil {
+		who += "@" + *msg.handle
+	}
+	if cm.joined[who] {
+		return
+	}
+	cm.joined[who] = true
+	cm.hook(&hookEvent{Event: hookJoin, Message: hookMessage(msg)})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fifo makes a named pipe in dir for a hook command to wait on
+func fifo(t *testing.T, dir string, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	out, err := exec.Command("mkfifo", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("mkfifo: %v: %s", err, out)
+	}
+	return path
+}
+
+// TestHooksQueue holds the only worker on a command that waits for the test,
+// fills the queue behind it, and checks that what doesn't fit is dropped and
+// that everything queued runs once the worker is let go
+func TestHooksQueue(t *testing.T) {
+	dir := t.TempDir()
+	started, gate := fifo(t, dir, "started"), fifo(t, dir, "gate")
+	ran := filepath.Join(dir, "ran")
+	failed := make(chan hookFailedMsg, 200)
+	one := 1
+	h, err := newHooks(&HooksConfig{
+		Concurrency: &one,
+		On: map[string][]string{
+			hookConnect: {"echo > '" + started + "'; read _ < '" + gate + "'"},
+			hookPublish: {"echo >> '" + ran + "'"},
+		},
+	}, func(msg tea.Msg) {
+		failed <- msg.(hookFailedMsg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.fire(&hookEvent{Event: hookConnect})
+	// the connect hook is running once it writes to started, so it is off
+	// the queue
+	_, err = os.ReadFile(started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const fired = 200
+	for range fired {
+		h.fire(&hookEvent{Event: hookPublish})
+	}
+	for range fired - hookQueue {
+		select {
+		case f := <-failed:
+			if f.event != hookPublish || !errors.Is(f.err, errHookQueueFull) {
+				t.Errorf("got %v from %s, want it dropped", f.err, f.event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("gave up waiting for the drops")
+		}
+	}
+
+	err = os.WriteFile(gate, []byte("\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.wait(10 * time.Second)
+	data, err := os.ReadFile(ran)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != hookQueue {
+		t.Errorf("%d commands ran after the queue was let go, want %d", n, hookQueue)
+	}
+	select {
+	case f := <-failed:
+		t.Errorf("%s failed: %v", f.event, f.err)
+	default:
+	}
+}
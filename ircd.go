@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/rachel-mp4/ttyxcvr/ircd"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/xcvr"
+)
+
+// ircdMain lets irc clients join lrc channels
+func ircdMain(args []string) int {
+	fs := flag.NewFlagSet("ircd", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:6667", "address to listen on")
+	color := fs.Uint("color", 33096, "color that irc users send messages as")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr ircd [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	server := ircd.New(xcvrDirectory{xcvr.NewClient(xcvr.DefaultHost)}, uint32(*color))
+	server.Log = logger
+	logger.Printf("serving irc on %s, /join #channel-title or #ws://host:port", l.Addr())
+	logger.Print(server.Serve(l))
+	return 1
+}
+
+// xcvrDirectory finds channels in the xcvr directory by their ircName, or
+// dials names that look like addresses
+type xcvrDirectory struct {
+	c *xcvr.Client
+}
+
+func (d xcvrDirectory) Resolve(ctx context.Context, name string) (string, error) {
+	// titles can't have a : in them once they are irc names, so anything
+	// with one is a host:port or a ws:// url
+	if strings.Contains(name, ":") {
+		return name, nil
+	}
+	channels, err := d.c.GetChannels(ctx)
+	if err != nil {
+		return "", errors.New("error getting channels: " + err.Error())
+	}
+	for _, cv := range channels {
+		if ircName(cv) != strings.ToLower(name) {
+			continue
+		}
+		return resolveJoin(ctx, d.c, cv.URI)
+	}
+	return "", errors.New("no channel called " + name)
+}
+
+func (d xcvrDirectory) List(ctx context.Context) ([]ircd.Channel, error) {
+	channels, err := d.c.GetChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ircd.Channel, 0, len(channels))
+	for _, cv := range channels {
+		c := ircd.Channel{Name: ircName(cv)}
+		if cv.Topic != nil {
+			c.Topic = *cv.Topic
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// ircName is what a channel is called on irc, its title in lowercase with
+// everything that irc wouldn't like turned into -
+func ircName(cv *lex.ChannelView) string {
+	name := strings.Map(func(r rune) rune {
+		if r == ' ' || r == ',' || r == ':' || r == '\a' || r < ' ' {
+			return '-'
+		}
+		return r
+	}, strings.ToLower(cv.Title))
+	return strings.Trim(name, "-")
+}
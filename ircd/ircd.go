@@ -0,0 +1,546 @@
+// Package ircd lets irc clients into lrc channels. every irc connection gets
+// its own lrc connection for each channel it joins, with its irc nick as its
+// lrc nick. messages are passed on to irc once they are pub'd, and while
+// they are being typed clients that asked for message-tags get +typing
+// notifications
+package ircd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+const (
+	serverName = "ttyxcvr"
+	// maxText is how many bytes of a message go in a single PRIVMSG, which
+	// leaves room in the 512 byte line for the prefix and channel
+	maxText = 400
+	// typingInterval is how often +typing=active is repeated while someone
+	// keeps typing, clients forget about it after 6 seconds
+	typingInterval = 3 * time.Second
+	// pingInterval is how long a client can be quiet before we check on it
+	pingInterval = 2 * time.Minute
+)
+
+// Channel is a channel that can be joined by name
+type Channel struct {
+	Name  string
+	Topic string
+}
+
+// Directory finds lrc channels for irc names, which don't have the # in front
+type Directory interface {
+	// Resolve returns the lrc websocket of the channel called name
+	Resolve(ctx context.Context, name string) (string, error)
+	// List returns the channels that can be joined by name, for LIST
+	List(ctx context.Context) ([]Channel, error)
+}
+
+// Server accepts irc connections
+type Server struct {
+	Directory Directory
+	// Color is the lrc color of everyone who comes in over irc
+	Color uint32
+	// Log gets a line whenever a client comes or goes, nil keeps quiet
+	Log *log.Logger
+}
+
+func New(dir Directory, color uint32) *Server {
+	return &Server{Directory: dir, Color: color}
+}
+
+// Serve accepts connections on l until it fails
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			s.logf("%s connected", conn.RemoteAddr())
+			sess := newSession(s, conn)
+			err := sess.run()
+			s.logf("%s disconnected: %v", conn.RemoteAddr(), err)
+		}()
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		s.Log.Printf(format, args...)
+	}
+}
+
+// session is one irc connection
+type session struct {
+	s    *Server
+	conn net.Conn
+	ctx  context.Context
+	stop func()
+
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	// these are only touched by run
+	user        string
+	registered  bool
+	negotiating bool
+
+	// mu guards what the channel listeners use as well. run is the only one
+	// that changes nick and tags, so it reads them without it
+	mu       sync.Mutex
+	nick     string
+	tags     bool
+	channels map[string]*channel
+}
+
+// channel is a channel that the session has joined
+type channel struct {
+	name string
+	lrc  *lrcclient.Client
+	// typing is when we last told the client that the message with that id
+	// is being typed
+	typing map[uint32]time.Time
+}
+
+func newSession(s *Server, conn net.Conn) *session {
+	ctx, stop := context.WithCancel(context.Background())
+	return &session{
+		s:        s,
+		conn:     conn,
+		ctx:      ctx,
+		stop:     stop,
+		w:        bufio.NewWriter(conn),
+		channels: make(map[string]*channel),
+	}
+}
+
+func (ss *session) run() error {
+	defer ss.conn.Close()
+	defer ss.stop()
+	defer ss.partAll()
+	r := bufio.NewReaderSize(ss.conn, maxLine)
+	for {
+		ss.conn.SetReadDeadline(time.Now().Add(pingInterval))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && ss.registered {
+				// one ping and the client has another interval to answer it
+				ss.send(&message{command: "PING", params: []string{serverName}})
+				ss.conn.SetReadDeadline(time.Now().Add(pingInterval))
+				line, err = r.ReadString('\n')
+			}
+			if err != nil {
+				return err
+			}
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		m, err := parse(line)
+		if err != nil {
+			continue
+		}
+		if quit := ss.handle(m); quit {
+			return nil
+		}
+	}
+}
+
+// send writes m to the client
+func (ss *session) send(m *message) {
+	ss.wmu.Lock()
+	defer ss.wmu.Unlock()
+	ss.w.WriteString(m.String())
+	ss.w.WriteString("\r\n")
+	ss.w.Flush()
+}
+
+// reply sends a numeric reply, which is always addressed to the client's nick
+func (ss *session) reply(numeric string, params ...string) {
+	nick := ss.nick
+	if nick == "" {
+		nick = "*"
+	}
+	ss.send(&message{prefix: serverName, command: numeric, params: append([]string{nick}, params...)})
+}
+
+// prefix is how the client itself appears in messages
+func (ss *session) prefix() string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.nick + "!" + ss.user + "@" + serverName
+}
+
+// handle runs a command from the client, reporting whether it quit
+func (ss *session) handle(m *message) bool {
+	switch m.command {
+	case "CAP":
+		ss.cap(m)
+	case "PASS":
+	case "NICK":
+		if len(m.params) < 1 {
+			ss.reply("431", "No nickname given")
+			return false
+		}
+		nick := m.params[0]
+		if ircNick(nick) != nick {
+			ss.reply("432", nick, "Erroneous nickname")
+			return false
+		}
+		if ss.registered {
+			ss.send(&message{prefix: ss.prefix(), command: "NICK", params: []string{nick}})
+			ss.setNick(nick)
+			return false
+		}
+		ss.setNick(nick)
+		ss.register()
+	case "USER":
+		if len(m.params) < 4 {
+			ss.reply("461", "USER", "Not enough parameters")
+			return false
+		}
+		ss.user = ircNick(m.params[0])
+		ss.register()
+	case "PING":
+		ss.send(&message{prefix: serverName, command: "PONG", params: append([]string{serverName}, m.params...)})
+	case "PONG":
+	case "QUIT":
+		ss.send(&message{command: "ERROR", params: []string{"bye"}})
+		return true
+	default:
+		if !ss.registered {
+			ss.reply("451", "You have not registered")
+			return false
+		}
+		ss.handleRegistered(m)
+	}
+	return false
+}
+
+func (ss *session) handleRegistered(m *message) {
+	switch m.command {
+	case "JOIN":
+		if len(m.params) < 1 {
+			ss.reply("461", "JOIN", "Not enough parameters")
+			return
+		}
+		for _, name := range strings.Split(m.params[0], ",") {
+			ss.join(name)
+		}
+	case "PART":
+		if len(m.params) < 1 {
+			ss.reply("461", "PART", "Not enough parameters")
+			return
+		}
+		for _, name := range strings.Split(m.params[0], ",") {
+			ss.part(name)
+		}
+	case "PRIVMSG", "NOTICE":
+		if len(m.params) < 2 {
+			ss.reply("412", "No text to send")
+			return
+		}
+		ss.privmsg(m.params[0], m.params[1])
+	case "TAGMSG":
+	case "LIST":
+		ss.list()
+	case "TOPIC":
+		if len(m.params) < 1 {
+			ss.reply("461", "TOPIC", "Not enough parameters")
+			return
+		}
+		if len(m.params) > 1 {
+			ss.reply("482", m.params[0], "lrc topics can't be changed")
+			return
+		}
+		ss.topic(m.params[0])
+	case "NAMES":
+		if len(m.params) > 0 {
+			ss.names(m.params[0])
+		}
+	case "MODE":
+		if len(m.params) == 1 && ss.joined(m.params[0]) != nil {
+			ss.reply("324", m.params[0], "+n")
+		}
+	case "WHO":
+		var mask string
+		if len(m.params) > 0 {
+			mask = m.params[0]
+		}
+		ss.reply("315", mask, "End of WHO list")
+	default:
+		ss.reply("421", m.command, "Unknown command")
+	}
+}
+
+// cap does just enough capability negotiation to offer message-tags, which
+// +typing needs
+func (ss *session) cap(m *message) {
+	if len(m.params) < 1 {
+		return
+	}
+	switch strings.ToUpper(m.params[0]) {
+	case "LS":
+		ss.negotiating = true
+		ss.send(&message{prefix: serverName, command: "CAP", params: []string{ss.nickOrStar(), "LS", "message-tags"}})
+	case "REQ":
+		ss.negotiating = true
+		var req string
+		if len(m.params) > 1 {
+			req = m.params[1]
+		}
+		caps := strings.Fields(req)
+		for _, c := range caps {
+			if c != "message-tags" {
+				ss.send(&message{prefix: serverName, command: "CAP", params: []string{ss.nickOrStar(), "NAK", req}})
+				return
+			}
+		}
+		ss.mu.Lock()
+		ss.tags = len(caps) > 0
+		ss.mu.Unlock()
+		ss.send(&message{prefix: serverName, command: "CAP", params: []string{ss.nickOrStar(), "ACK", req}})
+	case "LIST":
+		var list string
+		if ss.tags {
+			list = "message-tags"
+		}
+		ss.send(&message{prefix: serverName, command: "CAP", params: []string{ss.nickOrStar(), "LIST", list}})
+	case "END":
+		ss.negotiating = false
+		ss.register()
+	}
+}
+
+func (ss *session) nickOrStar() string {
+	if ss.nick == "" {
+		return "*"
+	}
+	return ss.nick
+}
+
+// register welcomes the client once it has said who it is and is done
+// negotiating
+func (ss *session) register() {
+	if ss.registered || ss.negotiating || ss.nick == "" || ss.user == "" {
+		return
+	}
+	ss.registered = true
+	ss.reply("001", "Welcome to lrc over irc, "+ss.nick)
+	ss.reply("002", "Your host is "+serverName)
+	ss.reply("003", "This server has no start date worth mentioning")
+	ss.reply("004", serverName, "ttyxcvr", "i", "n")
+	ss.reply("005", "CHANTYPES=#", "CASEMAPPING=ascii", "NICKLEN=64", "are supported by this server")
+	ss.reply("422", "MOTD File is missing")
+}
+
+func (ss *session) joined(name string) *channel {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.channels[strings.ToLower(name)]
+}
+
+func (ss *session) join(name string) {
+	if !strings.HasPrefix(name, "#") || len(name) < 2 {
+		ss.reply("403", name, "No such channel")
+		return
+	}
+	if ss.joined(name) != nil {
+		return
+	}
+	url, err := ss.s.Directory.Resolve(ss.ctx, name[1:])
+	if err != nil {
+		ss.reply("403", name, err.Error())
+		return
+	}
+	nick := ss.nick
+	color := ss.s.Color
+	c, err := lrcclient.Connect(ss.ctx, url, lrcclient.Config{Nick: &nick, Color: &color, Scrollback: 1})
+	if err != nil {
+		ss.reply("403", name, "couldn't connect: "+err.Error())
+		return
+	}
+	ch := &channel{name: name, lrc: c, typing: make(map[uint32]time.Time)}
+	ss.mu.Lock()
+	ss.channels[strings.ToLower(name)] = ch
+	ss.mu.Unlock()
+	ss.send(&message{prefix: ss.prefix(), command: "JOIN", params: []string{name}})
+	ss.reply("331", name, "No topic is set")
+	ss.names(name)
+	go ss.listen(ch)
+}
+
+func (ss *session) part(name string) {
+	ch := ss.joined(name)
+	if ch == nil {
+		ss.reply("442", name, "You're not on that channel")
+		return
+	}
+	ss.mu.Lock()
+	delete(ss.channels, strings.ToLower(name))
+	ss.mu.Unlock()
+	ch.lrc.Close()
+	ss.send(&message{prefix: ss.prefix(), command: "PART", params: []string{ch.name}})
+}
+
+func (ss *session) partAll() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for name, ch := range ss.channels {
+		ch.lrc.Close()
+		delete(ss.channels, name)
+	}
+}
+
+// names only lists the client, lrc doesn't say who else is there
+func (ss *session) names(name string) {
+	if ch := ss.joined(name); ch != nil {
+		ss.reply("353", "=", ch.name, ss.nick)
+	}
+	ss.reply("366", name, "End of NAMES list")
+}
+
+func (ss *session) topic(name string) {
+	ch := ss.joined(name)
+	if ch == nil {
+		ss.reply("442", name, "You're not on that channel")
+		return
+	}
+	topic, ok := ch.lrc.Topic()
+	if !ok || topic == "" {
+		ss.reply("331", ch.name, "No topic is set")
+		return
+	}
+	ss.reply("332", ch.name, topic)
+}
+
+func (ss *session) list() {
+	channels, err := ss.s.Directory.List(ss.ctx)
+	if err != nil {
+		ss.send(&message{prefix: serverName, command: "NOTICE", params: []string{ss.nick, "couldn't list channels: " + err.Error()}})
+	}
+	ss.reply("321", "Channel", "Users  Name")
+	for _, c := range channels {
+		ss.reply("322", "#"+c.Name, "0", c.Topic)
+	}
+	ss.reply("323", "End of LIST")
+}
+
+// privmsg sends text to an lrc channel as a whole message
+func (ss *session) privmsg(target string, text string) {
+	ch := ss.joined(target)
+	if ch == nil {
+		ss.reply("404", target, "Cannot send to channel")
+		return
+	}
+	if action, ok := strings.CutPrefix(text, "\x01ACTION "); ok {
+		text = "*" + strings.TrimSuffix(action, "\x01") + "*"
+	} else if strings.HasPrefix(text, "\x01") {
+		// other ctcp, like VERSION, has nobody to answer it
+		return
+	}
+	err := ch.lrc.Send(text)
+	if err != nil {
+		ss.send(&message{prefix: serverName, command: "NOTICE", params: []string{ch.name, "couldn't send: " + err.Error()}})
+	}
+}
+
+// setNick changes the client's nick here and in every channel it is in
+func (ss *session) setNick(nick string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.nick = nick
+	for _, ch := range ss.channels {
+		ch.lrc.SetIdentity(&nick, nil, &ss.s.Color)
+	}
+}
+
+// listen passes what happens in an lrc channel on to the client until the
+// channel is parted or lost
+func (ss *session) listen(ch *channel) {
+	for ev := range ch.lrc.Events() {
+		m := ev.Message
+		switch {
+		case ev.Kind == lrcclient.KindGet:
+			if topic := ev.Raw.GetGet().Topic; topic != nil && *topic != "" {
+				ss.send(&message{prefix: serverName, command: "TOPIC", params: []string{ch.name, *topic}})
+			}
+		case m == nil || m.Mine:
+		case ev.Kind == lrcclient.KindEdit && m.Active:
+			ss.typing(ch, m, "active")
+		case ev.Kind == lrcclient.KindPub:
+			delete(ch.typing, m.ID)
+			lines := split(m.Text, maxText)
+			if len(lines) == 0 {
+				ss.typing(ch, m, "done")
+			}
+			for _, line := range lines {
+				ss.send(&message{prefix: ss.from(m), command: "PRIVMSG", params: []string{ch.name, line}})
+			}
+		}
+	}
+	if ss.joined(ch.name) != ch {
+		// parted, or the session is over
+		return
+	}
+	ss.mu.Lock()
+	delete(ss.channels, strings.ToLower(ch.name))
+	ss.mu.Unlock()
+	reason := "lost the lrc connection"
+	if err := ch.lrc.Err(); err != nil {
+		reason += ": " + err.Error()
+	}
+	ss.send(&message{prefix: ss.prefix(), command: "PART", params: []string{ch.name, reason}})
+}
+
+// typing tells the client that m is being typed, or no longer is, at most
+// every typingInterval while it keeps being typed
+func (ss *session) typing(ch *channel, m *lrcclient.Message, state string) {
+	ss.mu.Lock()
+	tags := ss.tags
+	ss.mu.Unlock()
+	if !tags {
+		return
+	}
+	if state == "active" {
+		if time.Since(ch.typing[m.ID]) < typingInterval {
+			return
+		}
+		ch.typing[m.ID] = time.Now()
+	}
+	ss.send(&message{
+		tags:    map[string]string{"+typing": state},
+		prefix:  ss.from(m),
+		command: "TAGMSG",
+		params:  []string{ch.name},
+	})
+}
+
+// from is the prefix an lrc message is sent to irc with. people who have the
+// client's nick get a _ on the end so that their messages don't look like the
+// client's own
+func (ss *session) from(m *lrcclient.Message) string {
+	var nick string
+	if m.Nick != nil {
+		nick = *m.Nick
+	}
+	n := ircNick(nick)
+	ss.mu.Lock()
+	mine := strings.EqualFold(n, ss.nick)
+	ss.mu.Unlock()
+	if mine {
+		n += "_"
+	}
+	return n + "!lrc@" + ircHost(m.ExternalID)
+}
@@ -0,0 +1,173 @@
+package ircd
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// maxLine is how long a line from a client can be, the 512 bytes of rfc 1459
+// plus room for the tags that ircv3 allows in front
+const maxLine = 512 + 8191
+
+// message is one irc line
+type message struct {
+	tags    map[string]string
+	prefix  string
+	command string
+	params  []string
+}
+
+// parse reads an irc line without its trailing \r\n
+func parse(line string) (*message, error) {
+	m := &message{}
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		m.tags = make(map[string]string)
+		for _, tag := range strings.Split(tags, ";") {
+			k, v, _ := strings.Cut(tag, "=")
+			m.tags[k] = unescapeTag(v)
+		}
+		line = strings.TrimLeft(line, " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		m.prefix, line, _ = strings.Cut(line[1:], " ")
+		line = strings.TrimLeft(line, " ")
+	}
+	var rest string
+	m.command, rest, _ = strings.Cut(line, " ")
+	m.command = strings.ToUpper(m.command)
+	if m.command == "" {
+		return nil, errors.New("no command")
+	}
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(rest, ":") {
+			m.params = append(m.params, rest[1:])
+			break
+		}
+		var param string
+		param, rest, _ = strings.Cut(rest, " ")
+		if param != "" {
+			m.params = append(m.params, param)
+		}
+	}
+	return m, nil
+}
+
+// lineBreaks are what could end a line early or cut it short, they are taken
+// out of everything but tags on the way out
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ", "\x00", "")
+
+// String formats m as a line to send, without the trailing \r\n. the last
+// param is always sent as a trailing one so that it can have spaces
+func (m *message) String() string {
+	var b strings.Builder
+	if len(m.tags) > 0 {
+		b.WriteByte('@')
+		first := true
+		for k, v := range m.tags {
+			if !first {
+				b.WriteByte(';')
+			}
+			first = false
+			b.WriteString(k)
+			if v != "" {
+				b.WriteByte('=')
+				b.WriteString(escapeTag(v))
+			}
+		}
+		b.WriteByte(' ')
+	}
+	if m.prefix != "" {
+		b.WriteByte(':')
+		lineBreaks.WriteString(&b, m.prefix)
+		b.WriteByte(' ')
+	}
+	lineBreaks.WriteString(&b, m.command)
+	for i, p := range m.params {
+		b.WriteByte(' ')
+		if i == len(m.params)-1 {
+			b.WriteByte(':')
+		}
+		lineBreaks.WriteString(&b, p)
+	}
+	return b.String()
+}
+
+var tagEscapes = strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`)
+
+var tagUnescapes = strings.NewReplacer(`\\`, `\`, `\:`, ";", `\s`, " ", `\r`, "\r", `\n`, "\n")
+
+func escapeTag(v string) string {
+	return tagEscapes.Replace(v)
+}
+
+func unescapeTag(v string) string {
+	return tagUnescapes.Replace(v)
+}
+
+// nickChars are the characters other than letters and digits that irc allows
+// in a nick
+const nickChars = "[]\\`_^{|}-"
+
+// ircNick turns an lrc nick into something irc clients will accept as one,
+// anon if there's nothing left of it
+func ircNick(nick string) string {
+	var b strings.Builder
+	for _, r := range nick {
+		switch {
+		case r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case strings.ContainsRune(nickChars, r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	n := b.String()
+	if strings.Trim(n, "_") == "" {
+		return "anon"
+	}
+	if n[0] == '-' || n[0] >= '0' && n[0] <= '9' {
+		n = "_" + n
+	}
+	return n
+}
+
+// ircHost turns an lrc handle into the host part of a prefix
+func ircHost(handle *string) string {
+	if handle == nil || *handle == "" || strings.ContainsAny(*handle, " !@") || strings.ContainsFunc(*handle, unicode.IsControl) {
+		return "lrc"
+	}
+	return *handle
+}
+
+// split breaks text into lines that fit in a PRIVMSG, on newlines and then
+// every max bytes without cutting a rune in half. a \r anywhere else becomes
+// a space and a \x00 is dropped
+func split(text string, max int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = strings.ReplaceAll(line, "\r", " ")
+		line = strings.ReplaceAll(line, "\x00", "")
+		for len(line) > max {
+			cut := max
+			for cut > 0 && !isRuneStart(line[cut]) {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xc0 != 0x80
+}
@@ -0,0 +1,134 @@
+package ircd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line    string
+		tags    map[string]string
+		prefix  string
+		command string
+		params  []string
+	}{
+		{"PING", nil, "", "PING", nil},
+		{"privmsg #a :hi there", nil, "", "PRIVMSG", []string{"#a", "hi there"}},
+		{":nick!u@h PRIVMSG  #a  :", nil, "nick!u@h", "PRIVMSG", []string{"#a", ""}},
+		{"JOIN #a,#b", nil, "", "JOIN", []string{"#a,#b"}},
+		{"USER u 0 * :real name", nil, "", "USER", []string{"u", "0", "*", "real name"}},
+		{"@+typing=active;msgid=a\\sb\\:c TAGMSG #a", map[string]string{"+typing": "active", "msgid": "a b;c"}, "", "TAGMSG", []string{"#a"}},
+	}
+	for _, tt := range tests {
+		m, err := parse(tt.line)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.line, err)
+			continue
+		}
+		if len(m.tags) != len(tt.tags) {
+			t.Errorf("parse(%q) tags = %v, want %v", tt.line, m.tags, tt.tags)
+		}
+		for k, v := range tt.tags {
+			if m.tags[k] != v {
+				t.Errorf("parse(%q) tag %s = %q, want %q", tt.line, k, m.tags[k], v)
+			}
+		}
+		if m.prefix != tt.prefix || m.command != tt.command || !slices.Equal(m.params, tt.params) {
+			t.Errorf("parse(%q) = %q %q %q, want %q %q %q", tt.line, m.prefix, m.command, m.params, tt.prefix, tt.command, tt.params)
+		}
+	}
+	for _, line := range []string{"", ":prefix", "@a=b", "@a=b :prefix"} {
+		if _, err := parse(line); err == nil {
+			t.Errorf("parse(%q) has no error", line)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    message
+		want string
+	}{
+		{message{command: "PING", params: []string{"x"}}, "PING :x"},
+		{message{prefix: "srv", command: "332", params: []string{"me", "#a", "a topic"}}, ":srv 332 me #a :a topic"},
+		{message{tags: map[string]string{"+typing": "done"}, command: "TAGMSG", params: []string{"#a"}}, "@+typing=done TAGMSG :#a"},
+		{message{tags: map[string]string{"k": "a b\r\n"}, command: "TAGMSG", params: []string{"#a"}}, "@k=a\\sb\\r\\n TAGMSG :#a"},
+		// lrc text can't start a line of its own or cut one short
+		{message{prefix: "srv", command: "TOPIC", params: []string{"#a", "hi\r\nQUIT :bye"}}, ":srv TOPIC #a :hi  QUIT :bye"},
+		{message{prefix: "n\r\n!lrc@h", command: "PRIVMSG", params: []string{"#a", "a\x00b"}}, ":n  !lrc@h PRIVMSG #a :ab"},
+		{message{command: "PRIVMSG", params: []string{"#a\nKILL", "x"}}, "PRIVMSG #a KILL :x"},
+	}
+	for _, tt := range tests {
+		got := tt.m.String()
+		if got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if strings.ContainsAny(got, "\r\n\x00") {
+			t.Errorf("String() = %q has a line break in it", got)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want []string
+	}{
+		{"", 10, nil},
+		{"hello", 10, []string{"hello"}},
+		{"a\r\nb\n\nc\r", 10, []string{"a", "b", "c"}},
+		{"a\rb\x00c", 10, []string{"a bc"}},
+		{"abcdefgh", 3, []string{"abc", "def", "gh"}},
+		// é is two bytes and is never cut in half
+		{"aéé", 2, []string{"a", "é", "é"}},
+		{"éé", 3, []string{"é", "é"}},
+	}
+	for _, tt := range tests {
+		got := split(tt.text, tt.max)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("split(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+		for _, line := range got {
+			if len(line) > tt.max || strings.ContainsAny(line, "\r\n\x00") {
+				t.Errorf("split(%q, %d) has line %q", tt.text, tt.max, line)
+			}
+		}
+	}
+}
+
+func TestIRCHost(t *testing.T) {
+	tests := []struct {
+		handle *string
+		want   string
+	}{
+		{nil, "lrc"},
+		{ptr(""), "lrc"},
+		{ptr("alice.bsky.social"), "alice.bsky.social"},
+		{ptr("a b"), "lrc"},
+		{ptr("a!b"), "lrc"},
+		{ptr("a@b"), "lrc"},
+		{ptr("a\r\nQUIT"), "lrc"},
+		{ptr("a\x00b"), "lrc"},
+		{ptr("a\tb"), "lrc"},
+		{ptr("a\x7fb"), "lrc"},
+	}
+	for _, tt := range tests {
+		if got := ircHost(tt.handle); got != tt.want {
+			t.Errorf("ircHost(%q) = %q, want %q", deref(tt.handle), got, tt.want)
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// streamEvent is one line of pipe --json. type is the name of the lrc event,
+// like init, insert or editbatch, or signetView or messageView for the lex
+// stream. event is the event itself as it came in and message is what the
+// message it was about looks like after it was applied
+type streamEvent struct {
+	Type    string          `json:"type"`
+	Time    time.Time       `json:"time"`
+	ID      *uint32         `json:"id,omitempty"`
+	Mine    bool            `json:"mine,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+	Message *messageJSON    `json:"message,omitempty"`
+}
+
+// lrcStreamEvent describes e without the message it is about
+func lrcStreamEvent(e *lrcpb.Event) (*streamEvent, error) {
+	se := &streamEvent{Time: time.Now(), ID: eventID(e)}
+	r := e.ProtoReflect()
+	fd := r.WhichOneof(r.Descriptor().Oneofs().ByName("msg"))
+	if fd == nil {
+		se.Type = "unknown"
+		return se, nil
+	}
+	se.Type = string(fd.Name())
+	data, err := protojson.Marshal(r.Get(fd).Message().Interface())
+	if err != nil {
+		return nil, err
+	}
+	se.Event = data
+	return se, nil
+}
+
+// eventID is the id of the message that e is about, from the event if it has
+// one and otherwise from whichever of its fields carries it
+func eventID(e *lrcpb.Event) *uint32 {
+	if e.Id != nil {
+		return e.Id
+	}
+	var id uint32
+	switch msg := e.Msg.(type) {
+	case *lrcpb.Event_Init:
+		if msg.Init == nil {
+			return nil
+		}
+		return msg.Init.Id
+	case *lrcpb.Event_Pub:
+		if msg.Pub == nil {
+			return nil
+		}
+		return msg.Pub.Id
+	case *lrcpb.Event_Insert:
+		if msg.Insert == nil {
+			return nil
+		}
+		return msg.Insert.Id
+	case *lrcpb.Event_Delete:
+		if msg.Delete == nil {
+			return nil
+		}
+		return msg.Delete.Id
+	case *lrcpb.Event_Mute:
+		id = msg.Mute.GetId()
+	case *lrcpb.Event_Unmute:
+		id = msg.Unmute.GetId()
+	case *lrcpb.Event_Kick:
+		id = msg.Kick.GetId()
+	case *lrcpb.Event_Hug:
+		id = msg.Hug.GetId()
+	case *lrcpb.Event_Ban:
+		id = msg.Ban.GetId()
+	case *lrcpb.Event_Unban:
+		id = msg.Unban.GetId()
+	default:
+		return nil
+	}
+	return &id
+}
+
+// lexStreamEvent describes a lex stream message of the given type
+func lexStreamEvent(typ string, id *uint32, v any) (*streamEvent, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &streamEvent{Type: typ, Time: time.Now(), ID: id, Event: data}, nil
+}
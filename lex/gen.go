@@ -0,0 +1,6 @@
+// Package lex holds the org.xcvr lexicon types, everything but this file and
+// validate.go is generated from the json in lexicons/
+package lex
+
+//go:generate go run ../cmd/lexgen -lexicons ../lexicons -out . -cbortypes ../cmd/cborgen/types.go
+//go:generate go run -tags lexgen ../cmd/cborgen -out lexicons_cbor.go
@@ -1,3 +1,5 @@
+//go:build !lexgen
+
 // Code generated by github.com/whyrusleeping/cbor-gen. DO NOT EDIT.
 
 package lex
@@ -53,7 +55,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	}
 
 	// t.LexiconTypeID (string) (string)
-	if len("$type") > 8192 {
+	if len("$type") > 1000000 {
 		return xerrors.Errorf("Value in field \"$type\" was too long")
 	}
 
@@ -74,7 +76,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	// t.Color (uint64) (uint64)
 	if t.Color != nil {
 
-		if len("color") > 8192 {
+		if len("color") > 1000000 {
 			return xerrors.Errorf("Value in field \"color\" was too long")
 		}
 
@@ -100,7 +102,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	// t.Avatar (util.LexBlob) (struct)
 	if t.Avatar != nil {
 
-		if len("avatar") > 8192 {
+		if len("avatar") > 1000000 {
 			return xerrors.Errorf("Value in field \"avatar\" was too long")
 		}
 
@@ -119,7 +121,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	// t.Status (string) (string)
 	if t.Status != nil {
 
-		if len("status") > 8192 {
+		if len("status") > 1000000 {
 			return xerrors.Errorf("Value in field \"status\" was too long")
 		}
 
@@ -135,7 +137,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 				return err
 			}
 		} else {
-			if len(*t.Status) > 8192 {
+			if len(*t.Status) > 1000000 {
 				return xerrors.Errorf("Value in field t.Status was too long")
 			}
 
@@ -151,7 +153,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	// t.DefaultNick (string) (string)
 	if t.DefaultNick != nil {
 
-		if len("defaultNick") > 8192 {
+		if len("defaultNick") > 1000000 {
 			return xerrors.Errorf("Value in field \"defaultNick\" was too long")
 		}
 
@@ -167,7 +169,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 				return err
 			}
 		} else {
-			if len(*t.DefaultNick) > 8192 {
+			if len(*t.DefaultNick) > 1000000 {
 				return xerrors.Errorf("Value in field t.DefaultNick was too long")
 			}
 
@@ -183,7 +185,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 	// t.DisplayName (string) (string)
 	if t.DisplayName != nil {
 
-		if len("displayName") > 8192 {
+		if len("displayName") > 1000000 {
 			return xerrors.Errorf("Value in field \"displayName\" was too long")
 		}
 
@@ -199,7 +201,7 @@ func (t *ProfileRecord) MarshalCBOR(w io.Writer) error {
 				return err
 			}
 		} else {
-			if len(*t.DisplayName) > 8192 {
+			if len(*t.DisplayName) > 1000000 {
 				return xerrors.Errorf("Value in field t.DisplayName was too long")
 			}
 
@@ -241,7 +243,7 @@ func (t *ProfileRecord) UnmarshalCBOR(r io.Reader) (err error) {
 
 	nameBuf := make([]byte, 11)
 	for i := uint64(0); i < n; i++ {
-		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 8192)
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
 		if err != nil {
 			return err
 		}
@@ -259,7 +261,7 @@ func (t *ProfileRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "$type":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
@@ -324,7 +326,7 @@ func (t *ProfileRecord) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
 					if err != nil {
 						return err
 					}
@@ -345,7 +347,7 @@ func (t *ProfileRecord) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
 					if err != nil {
 						return err
 					}
@@ -366,7 +368,7 @@ func (t *ProfileRecord) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
 					if err != nil {
 						return err
 					}
@@ -403,7 +405,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 	}
 
 	// t.Host (string) (string)
-	if len("host") > 8192 {
+	if len("host") > 1000000 {
 		return xerrors.Errorf("Value in field \"host\" was too long")
 	}
 
@@ -414,7 +416,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	if len(t.Host) > 8192 {
+	if len(t.Host) > 1000000 {
 		return xerrors.Errorf("Value in field t.Host was too long")
 	}
 
@@ -426,7 +428,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 	}
 
 	// t.LexiconTypeID (string) (string)
-	if len("$type") > 8192 {
+	if len("$type") > 1000000 {
 		return xerrors.Errorf("Value in field \"$type\" was too long")
 	}
 
@@ -445,7 +447,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 	}
 
 	// t.Title (string) (string)
-	if len("title") > 8192 {
+	if len("title") > 1000000 {
 		return xerrors.Errorf("Value in field \"title\" was too long")
 	}
 
@@ -456,7 +458,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	if len(t.Title) > 8192 {
+	if len(t.Title) > 1000000 {
 		return xerrors.Errorf("Value in field t.Title was too long")
 	}
 
@@ -470,7 +472,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 	// t.Topic (string) (string)
 	if t.Topic != nil {
 
-		if len("topic") > 8192 {
+		if len("topic") > 1000000 {
 			return xerrors.Errorf("Value in field \"topic\" was too long")
 		}
 
@@ -486,7 +488,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 				return err
 			}
 		} else {
-			if len(*t.Topic) > 8192 {
+			if len(*t.Topic) > 1000000 {
 				return xerrors.Errorf("Value in field t.Topic was too long")
 			}
 
@@ -500,7 +502,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 	}
 
 	// t.CreatedAt (string) (string)
-	if len("createdAt") > 8192 {
+	if len("createdAt") > 1000000 {
 		return xerrors.Errorf("Value in field \"createdAt\" was too long")
 	}
 
@@ -511,7 +513,7 @@ func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	if len(t.CreatedAt) > 8192 {
+	if len(t.CreatedAt) > 1000000 {
 		return xerrors.Errorf("Value in field t.CreatedAt was too long")
 	}
 
@@ -551,7 +553,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 
 	nameBuf := make([]byte, 9)
 	for i := uint64(0); i < n; i++ {
-		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 8192)
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
 		if err != nil {
 			return err
 		}
@@ -569,7 +571,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "host":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
@@ -580,7 +582,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "$type":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
@@ -591,7 +593,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "title":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
@@ -611,7 +613,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
 					if err != nil {
 						return err
 					}
@@ -623,7 +625,7 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "createdAt":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
@@ -641,20 +643,20 @@ func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) (err error) {
 
 	return nil
 }
-func (t *MessageRecord) MarshalCBOR(w io.Writer) error {
+func (t *Image) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
 
 	cw := cbg.NewCborWriter(w)
-	fieldCount := 6
+	fieldCount := 4
 
-	if t.Nick == nil {
+	if t.AspectRatio == nil {
 		fieldCount--
 	}
 
-	if t.Color == nil {
+	if t.Image == nil {
 		fieldCount--
 	}
 
@@ -662,63 +664,31 @@ func (t *MessageRecord) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	// t.Body (string) (string)
-	if len("body") > 8192 {
-		return xerrors.Errorf("Value in field \"body\" was too long")
+	// t.Alt (string) (string)
+	if len("alt") > 1000000 {
+		return xerrors.Errorf("Value in field \"alt\" was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("body"))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("alt"))); err != nil {
 		return err
 	}
-	if _, err := cw.WriteString(string("body")); err != nil {
+	if _, err := cw.WriteString(string("alt")); err != nil {
 		return err
 	}
 
-	if len(t.Body) > 8192 {
-		return xerrors.Errorf("Value in field t.Body was too long")
+	if len(t.Alt) > 1000000 {
+		return xerrors.Errorf("Value in field t.Alt was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Body))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Alt))); err != nil {
 		return err
 	}
-	if _, err := cw.WriteString(string(t.Body)); err != nil {
+	if _, err := cw.WriteString(string(t.Alt)); err != nil {
 		return err
 	}
 
-	// t.Nick (string) (string)
-	if t.Nick != nil {
-
-		if len("nick") > 8192 {
-			return xerrors.Errorf("Value in field \"nick\" was too long")
-		}
-
-		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("nick"))); err != nil {
-			return err
-		}
-		if _, err := cw.WriteString(string("nick")); err != nil {
-			return err
-		}
-
-		if t.Nick == nil {
-			if _, err := cw.Write(cbg.CborNull); err != nil {
-				return err
-			}
-		} else {
-			if len(*t.Nick) > 8192 {
-				return xerrors.Errorf("Value in field t.Nick was too long")
-			}
-
-			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Nick))); err != nil {
-				return err
-			}
-			if _, err := cw.WriteString(string(*t.Nick)); err != nil {
-				return err
-			}
-		}
-	}
-
 	// t.LexiconTypeID (string) (string)
-	if len("$type") > 8192 {
+	if len("$type") > 1000000 {
 		return xerrors.Errorf("Value in field \"$type\" was too long")
 	}
 
@@ -729,89 +699,55 @@ func (t *MessageRecord) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.message"))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.image"))); err != nil {
 		return err
 	}
-	if _, err := cw.WriteString(string("org.xcvr.lrc.message")); err != nil {
+	if _, err := cw.WriteString(string("org.xcvr.lrc.image")); err != nil {
 		return err
 	}
 
-	// t.Color (uint64) (uint64)
-	if t.Color != nil {
+	// t.Image (util.LexBlob) (struct)
+	if t.Image != nil {
 
-		if len("color") > 8192 {
-			return xerrors.Errorf("Value in field \"color\" was too long")
+		if len("image") > 1000000 {
+			return xerrors.Errorf("Value in field \"image\" was too long")
 		}
 
-		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("color"))); err != nil {
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("image"))); err != nil {
 			return err
 		}
-		if _, err := cw.WriteString(string("color")); err != nil {
+		if _, err := cw.WriteString(string("image")); err != nil {
 			return err
 		}
 
-		if t.Color == nil {
-			if _, err := cw.Write(cbg.CborNull); err != nil {
-				return err
-			}
-		} else {
-			if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(*t.Color)); err != nil {
-				return err
-			}
+		if err := t.Image.MarshalCBOR(cw); err != nil {
+			return err
 		}
-
-	}
-
-	// t.PostedAt (string) (string)
-	if len("postedAt") > 8192 {
-		return xerrors.Errorf("Value in field \"postedAt\" was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("postedAt"))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string("postedAt")); err != nil {
-		return err
-	}
-
-	if len(t.PostedAt) > 8192 {
-		return xerrors.Errorf("Value in field t.PostedAt was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.PostedAt))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string(t.PostedAt)); err != nil {
-		return err
 	}
 
-	// t.SignetURI (string) (string)
-	if len("signetURI") > 8192 {
-		return xerrors.Errorf("Value in field \"signetURI\" was too long")
-	}
+	// t.AspectRatio (lex.AspectRatio) (struct)
+	if t.AspectRatio != nil {
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("signetURI"))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string("signetURI")); err != nil {
-		return err
-	}
+		if len("aspectRatio") > 1000000 {
+			return xerrors.Errorf("Value in field \"aspectRatio\" was too long")
+		}
 
-	if len(t.SignetURI) > 8192 {
-		return xerrors.Errorf("Value in field t.SignetURI was too long")
-	}
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("aspectRatio"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("aspectRatio")); err != nil {
+			return err
+		}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.SignetURI))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string(t.SignetURI)); err != nil {
-		return err
+		if err := t.AspectRatio.MarshalCBOR(cw); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = MessageRecord{}
+func (t *Image) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = Image{}
 
 	cr := cbg.NewCborReader(r)
 
@@ -830,14 +766,14 @@ func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("MessageRecord: map struct too large (%d)", extra)
+		return fmt.Errorf("Image: map struct too large (%d)", extra)
 	}
 
 	n := extra
 
-	nameBuf := make([]byte, 9)
+	nameBuf := make([]byte, 11)
 	for i := uint64(0); i < n; i++ {
-		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 8192)
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
 		if err != nil {
 			return err
 		}
@@ -851,51 +787,30 @@ func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		switch string(nameBuf[:nameLen]) {
-		// t.Body (string) (string)
-		case "body":
-
-			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
-				if err != nil {
-					return err
-				}
-
-				t.Body = string(sval)
-			}
-			// t.Nick (string) (string)
-		case "nick":
+		// t.Alt (string) (string)
+		case "alt":
 
 			{
-				b, err := cr.ReadByte()
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
-				if b != cbg.CborNull[0] {
-					if err := cr.UnreadByte(); err != nil {
-						return err
-					}
-
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
-					if err != nil {
-						return err
-					}
 
-					t.Nick = (*string)(&sval)
-				}
+				t.Alt = string(sval)
 			}
 			// t.LexiconTypeID (string) (string)
 		case "$type":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
 
 				t.LexiconTypeID = string(sval)
 			}
-			// t.Color (uint64) (uint64)
-		case "color":
+			// t.Image (util.LexBlob) (struct)
+		case "image":
 
 			{
 
@@ -907,39 +822,32 @@ func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
 					if err := cr.UnreadByte(); err != nil {
 						return err
 					}
-					maj, extra, err = cr.ReadHeader()
-					if err != nil {
-						return err
-					}
-					if maj != cbg.MajUnsignedInt {
-						return fmt.Errorf("wrong type for uint64 field")
+					t.Image = new(util.LexBlob)
+					if err := t.Image.UnmarshalCBOR(cr); err != nil {
+						return xerrors.Errorf("unmarshaling t.Image pointer: %w", err)
 					}
-					typed := uint64(extra)
-					t.Color = &typed
 				}
 
 			}
-			// t.PostedAt (string) (string)
-		case "postedAt":
+			// t.AspectRatio (lex.AspectRatio) (struct)
+		case "aspectRatio":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
-				if err != nil {
-					return err
-				}
-
-				t.PostedAt = string(sval)
-			}
-			// t.SignetURI (string) (string)
-		case "signetURI":
 
-			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				b, err := cr.ReadByte()
 				if err != nil {
 					return err
 				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					t.AspectRatio = new(AspectRatio)
+					if err := t.AspectRatio.UnmarshalCBOR(cr); err != nil {
+						return xerrors.Errorf("unmarshaling t.AspectRatio pointer: %w", err)
+					}
+				}
 
-				t.SignetURI = string(sval)
 			}
 
 		default:
@@ -952,140 +860,55 @@ func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
 
 	return nil
 }
-func (t *SignetRecord) MarshalCBOR(w io.Writer) error {
+func (t *AspectRatio) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
 
 	cw := cbg.NewCborWriter(w)
-	fieldCount := 5
-
-	if t.StartedAt == nil {
-		fieldCount--
-	}
-
-	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
-		return err
-	}
-
-	// t.Author (string) (string)
-	if len("nick") > 8192 {
-		return xerrors.Errorf("Value in field \"nick\" was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("nick"))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string("nick")); err != nil {
-		return err
-	}
-
-	if len(t.AuthorHandle) > 8192 {
-		return xerrors.Errorf("Value in field t.Author was too long")
-	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.AuthorHandle))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string(t.AuthorHandle)); err != nil {
+	if _, err := cw.Write([]byte{162}); err != nil {
 		return err
 	}
 
-	// t.LexiconTypeID (string) (string)
-	if len("$type") > 8192 {
-		return xerrors.Errorf("Value in field \"$type\" was too long")
+	// t.Width (uint64) (uint64)
+	if len("width") > 1000000 {
+		return xerrors.Errorf("Value in field \"width\" was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("$type"))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("width"))); err != nil {
 		return err
 	}
-	if _, err := cw.WriteString(string("$type")); err != nil {
+	if _, err := cw.WriteString(string("width")); err != nil {
 		return err
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.signet"))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string("org.xcvr.lrc.signet")); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Width)); err != nil {
 		return err
 	}
 
-	// t.LRCID (uint64) (uint64)
-	if len("lrcID") > 8192 {
-		return xerrors.Errorf("Value in field \"lrcID\" was too long")
+	// t.Height (uint64) (uint64)
+	if len("height") > 1000000 {
+		return xerrors.Errorf("Value in field \"height\" was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("lrcID"))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string("lrcID")); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("height"))); err != nil {
 		return err
 	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.LRCID)); err != nil {
+	if _, err := cw.WriteString(string("height")); err != nil {
 		return err
 	}
 
-	// t.StartedAt (string) (string)
-	if t.StartedAt != nil {
-
-		if len("startedAt") > 8192 {
-			return xerrors.Errorf("Value in field \"startedAt\" was too long")
-		}
-
-		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("startedAt"))); err != nil {
-			return err
-		}
-		if _, err := cw.WriteString(string("startedAt")); err != nil {
-			return err
-		}
-
-		if t.StartedAt == nil {
-			if _, err := cw.Write(cbg.CborNull); err != nil {
-				return err
-			}
-		} else {
-			if len(*t.StartedAt) > 8192 {
-				return xerrors.Errorf("Value in field t.StartedAt was too long")
-			}
-
-			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.StartedAt))); err != nil {
-				return err
-			}
-			if _, err := cw.WriteString(string(*t.StartedAt)); err != nil {
-				return err
-			}
-		}
-	}
-
-	// t.ChannelURI (string) (string)
-	if len("channelURI") > 8192 {
-		return xerrors.Errorf("Value in field \"channelURI\" was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("channelURI"))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Height)); err != nil {
 		return err
 	}
-	if _, err := cw.WriteString(string("channelURI")); err != nil {
-		return err
-	}
-
-	if len(t.ChannelURI) > 8192 {
-		return xerrors.Errorf("Value in field t.ChannelURI was too long")
-	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.ChannelURI))); err != nil {
-		return err
-	}
-	if _, err := cw.WriteString(string(t.ChannelURI)); err != nil {
-		return err
-	}
 	return nil
 }
 
-func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = SignetRecord{}
+func (t *AspectRatio) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = AspectRatio{}
 
 	cr := cbg.NewCborReader(r)
 
@@ -1104,14 +927,14 @@ func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("SignetRecord: map struct too large (%d)", extra)
+		return fmt.Errorf("AspectRatio: map struct too large (%d)", extra)
 	}
 
 	n := extra
 
-	nameBuf := make([]byte, 10)
+	nameBuf := make([]byte, 6)
 	for i := uint64(0); i < n; i++ {
-		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 8192)
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
 		if err != nil {
 			return err
 		}
@@ -1125,29 +948,856 @@ func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		switch string(nameBuf[:nameLen]) {
-		// t.Author (string) (string)
-		case "authorHandle":
+		// t.Width (uint64) (uint64)
+		case "width":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+
+				maj, extra, err = cr.ReadHeader()
 				if err != nil {
 					return err
 				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.Width = uint64(extra)
 
-				t.AuthorHandle = string(sval)
 			}
-			// t.LexiconTypeID (string) (string)
-		case "$type":
+			// t.Height (uint64) (uint64)
+		case "height":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+
+				maj, extra, err = cr.ReadHeader()
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.Height = uint64(extra)
+
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(r, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *MediaRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+	fieldCount := 6
+
+	if t.Nick == nil {
+		fieldCount--
+	}
+
+	if t.Color == nil {
+		fieldCount--
+	}
+
+	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
+		return err
+	}
+
+	// t.Nick (string) (string)
+	if t.Nick != nil {
+
+		if len("nick") > 1000000 {
+			return xerrors.Errorf("Value in field \"nick\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("nick"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("nick")); err != nil {
+			return err
+		}
+
+		if t.Nick == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Nick) > 1000000 {
+				return xerrors.Errorf("Value in field t.Nick was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Nick))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Nick)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.LexiconTypeID (string) (string)
+	if len("$type") > 1000000 {
+		return xerrors.Errorf("Value in field \"$type\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("$type"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("$type")); err != nil {
+		return err
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.media"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("org.xcvr.lrc.media")); err != nil {
+		return err
+	}
+
+	// t.Color (uint64) (uint64)
+	if t.Color != nil {
+
+		if len("color") > 1000000 {
+			return xerrors.Errorf("Value in field \"color\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("color"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("color")); err != nil {
+			return err
+		}
+
+		if t.Color == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(*t.Color)); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	// t.Media (lex.MediaRecord_Media) (struct)
+	if len("media") > 1000000 {
+		return xerrors.Errorf("Value in field \"media\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("media"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("media")); err != nil {
+		return err
+	}
+
+	if err := t.Media.MarshalCBOR(cw); err != nil {
+		return err
+	}
+
+	// t.PostedAt (string) (string)
+	if len("postedAt") > 1000000 {
+		return xerrors.Errorf("Value in field \"postedAt\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("postedAt"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("postedAt")); err != nil {
+		return err
+	}
+
+	if len(t.PostedAt) > 1000000 {
+		return xerrors.Errorf("Value in field t.PostedAt was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.PostedAt))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.PostedAt)); err != nil {
+		return err
+	}
+
+	// t.SignetURI (string) (string)
+	if len("signetURI") > 1000000 {
+		return xerrors.Errorf("Value in field \"signetURI\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("signetURI"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("signetURI")); err != nil {
+		return err
+	}
+
+	if len(t.SignetURI) > 1000000 {
+		return xerrors.Errorf("Value in field t.SignetURI was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.SignetURI))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.SignetURI)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MediaRecord) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = MediaRecord{}
+
+	cr := cbg.NewCborReader(r)
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("MediaRecord: map struct too large (%d)", extra)
+	}
+
+	n := extra
+
+	nameBuf := make([]byte, 9)
+	for i := uint64(0); i < n; i++ {
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch string(nameBuf[:nameLen]) {
+		// t.Nick (string) (string)
+		case "nick":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Nick = (*string)(&sval)
+				}
+			}
+			// t.LexiconTypeID (string) (string)
+		case "$type":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.LexiconTypeID = string(sval)
+			}
+			// t.Color (uint64) (uint64)
+		case "color":
+
+			{
+
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					maj, extra, err = cr.ReadHeader()
+					if err != nil {
+						return err
+					}
+					if maj != cbg.MajUnsignedInt {
+						return fmt.Errorf("wrong type for uint64 field")
+					}
+					typed := uint64(extra)
+					t.Color = &typed
+				}
+
+			}
+			// t.Media (lex.MediaRecord_Media) (struct)
+		case "media":
+
+			{
+
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					t.Media = new(MediaRecord_Media)
+					if err := t.Media.UnmarshalCBOR(cr); err != nil {
+						return xerrors.Errorf("unmarshaling t.Media pointer: %w", err)
+					}
+				}
+
+			}
+			// t.PostedAt (string) (string)
+		case "postedAt":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.PostedAt = string(sval)
+			}
+			// t.SignetURI (string) (string)
+		case "signetURI":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.SignetURI = string(sval)
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(r, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *MessageRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+	fieldCount := 6
+
+	if t.Nick == nil {
+		fieldCount--
+	}
+
+	if t.Color == nil {
+		fieldCount--
+	}
+
+	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
+		return err
+	}
+
+	// t.Body (string) (string)
+	if len("body") > 1000000 {
+		return xerrors.Errorf("Value in field \"body\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("body"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("body")); err != nil {
+		return err
+	}
+
+	if len(t.Body) > 1000000 {
+		return xerrors.Errorf("Value in field t.Body was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Body))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.Body)); err != nil {
+		return err
+	}
+
+	// t.Nick (string) (string)
+	if t.Nick != nil {
+
+		if len("nick") > 1000000 {
+			return xerrors.Errorf("Value in field \"nick\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("nick"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("nick")); err != nil {
+			return err
+		}
+
+		if t.Nick == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Nick) > 1000000 {
+				return xerrors.Errorf("Value in field t.Nick was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Nick))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Nick)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.LexiconTypeID (string) (string)
+	if len("$type") > 1000000 {
+		return xerrors.Errorf("Value in field \"$type\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("$type"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("$type")); err != nil {
+		return err
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.message"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("org.xcvr.lrc.message")); err != nil {
+		return err
+	}
+
+	// t.Color (uint64) (uint64)
+	if t.Color != nil {
+
+		if len("color") > 1000000 {
+			return xerrors.Errorf("Value in field \"color\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("color"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("color")); err != nil {
+			return err
+		}
+
+		if t.Color == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(*t.Color)); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	// t.PostedAt (string) (string)
+	if len("postedAt") > 1000000 {
+		return xerrors.Errorf("Value in field \"postedAt\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("postedAt"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("postedAt")); err != nil {
+		return err
+	}
+
+	if len(t.PostedAt) > 1000000 {
+		return xerrors.Errorf("Value in field t.PostedAt was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.PostedAt))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.PostedAt)); err != nil {
+		return err
+	}
+
+	// t.SignetURI (string) (string)
+	if len("signetURI") > 1000000 {
+		return xerrors.Errorf("Value in field \"signetURI\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("signetURI"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("signetURI")); err != nil {
+		return err
+	}
+
+	if len(t.SignetURI) > 1000000 {
+		return xerrors.Errorf("Value in field t.SignetURI was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.SignetURI))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.SignetURI)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MessageRecord) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = MessageRecord{}
+
+	cr := cbg.NewCborReader(r)
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("MessageRecord: map struct too large (%d)", extra)
+	}
+
+	n := extra
+
+	nameBuf := make([]byte, 9)
+	for i := uint64(0); i < n; i++ {
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch string(nameBuf[:nameLen]) {
+		// t.Body (string) (string)
+		case "body":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.Body = string(sval)
+			}
+			// t.Nick (string) (string)
+		case "nick":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Nick = (*string)(&sval)
+				}
+			}
+			// t.LexiconTypeID (string) (string)
+		case "$type":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.LexiconTypeID = string(sval)
+			}
+			// t.Color (uint64) (uint64)
+		case "color":
+
+			{
+
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					maj, extra, err = cr.ReadHeader()
+					if err != nil {
+						return err
+					}
+					if maj != cbg.MajUnsignedInt {
+						return fmt.Errorf("wrong type for uint64 field")
+					}
+					typed := uint64(extra)
+					t.Color = &typed
+				}
+
+			}
+			// t.PostedAt (string) (string)
+		case "postedAt":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.PostedAt = string(sval)
+			}
+			// t.SignetURI (string) (string)
+		case "signetURI":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.SignetURI = string(sval)
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(r, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *SignetRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+	fieldCount := 5
+
+	if t.StartedAt == nil {
+		fieldCount--
+	}
+
+	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
+		return err
+	}
+
+	// t.LexiconTypeID (string) (string)
+	if len("$type") > 1000000 {
+		return xerrors.Errorf("Value in field \"$type\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("$type"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("$type")); err != nil {
+		return err
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("org.xcvr.lrc.signet"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("org.xcvr.lrc.signet")); err != nil {
+		return err
+	}
+
+	// t.LrcID (uint64) (uint64)
+	if len("lrcID") > 1000000 {
+		return xerrors.Errorf("Value in field \"lrcID\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("lrcID"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("lrcID")); err != nil {
+		return err
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.LrcID)); err != nil {
+		return err
+	}
+
+	// t.StartedAt (string) (string)
+	if t.StartedAt != nil {
+
+		if len("startedAt") > 1000000 {
+			return xerrors.Errorf("Value in field \"startedAt\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("startedAt"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("startedAt")); err != nil {
+			return err
+		}
+
+		if t.StartedAt == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.StartedAt) > 1000000 {
+				return xerrors.Errorf("Value in field t.StartedAt was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.StartedAt))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.StartedAt)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.ChannelURI (string) (string)
+	if len("channelURI") > 1000000 {
+		return xerrors.Errorf("Value in field \"channelURI\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("channelURI"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("channelURI")); err != nil {
+		return err
+	}
+
+	if len(t.ChannelURI) > 1000000 {
+		return xerrors.Errorf("Value in field t.ChannelURI was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.ChannelURI))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.ChannelURI)); err != nil {
+		return err
+	}
+
+	// t.AuthorHandle (string) (string)
+	if len("authorHandle") > 1000000 {
+		return xerrors.Errorf("Value in field \"authorHandle\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("authorHandle"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("authorHandle")); err != nil {
+		return err
+	}
+
+	if len(t.AuthorHandle) > 1000000 {
+		return xerrors.Errorf("Value in field t.AuthorHandle was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.AuthorHandle))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.AuthorHandle)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = SignetRecord{}
+
+	cr := cbg.NewCborReader(r)
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("SignetRecord: map struct too large (%d)", extra)
+	}
+
+	n := extra
+
+	nameBuf := make([]byte, 12)
+	for i := uint64(0); i < n; i++ {
+		nameLen, ok, err := cbg.ReadFullStringIntoBuf(cr, nameBuf, 1000000)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch string(nameBuf[:nameLen]) {
+		// t.LexiconTypeID (string) (string)
+		case "$type":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
 
 				t.LexiconTypeID = string(sval)
 			}
-			// t.LRCID (uint64) (uint64)
+			// t.LrcID (uint64) (uint64)
 		case "lrcID":
 
 			{
@@ -1159,7 +1809,7 @@ func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
 				if maj != cbg.MajUnsignedInt {
 					return fmt.Errorf("wrong type for uint64 field")
 				}
-				t.LRCID = uint64(extra)
+				t.LrcID = uint64(extra)
 
 			}
 			// t.StartedAt (string) (string)
@@ -1175,7 +1825,7 @@ func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 
-					sval, err := cbg.ReadStringWithMax(cr, 8192)
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
 					if err != nil {
 						return err
 					}
@@ -1187,13 +1837,24 @@ func (t *SignetRecord) UnmarshalCBOR(r io.Reader) (err error) {
 		case "channelURI":
 
 			{
-				sval, err := cbg.ReadStringWithMax(cr, 8192)
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
 				if err != nil {
 					return err
 				}
 
 				t.ChannelURI = string(sval)
 			}
+			// t.AuthorHandle (string) (string)
+		case "authorHandle":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.AuthorHandle = string(sval)
+			}
 
 		default:
 			// Field doesn't exist on this type, so ignore it
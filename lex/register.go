@@ -0,0 +1,47 @@
+//go:build !lexgen
+
+// Code generated by cmd/lexgen. DO NOT EDIT.
+
+package lex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bluesky-social/indigo/lex/util"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func init() {
+	util.RegisterType("org.xcvr.actor.profile", &ProfileRecord{})
+	util.RegisterType("org.xcvr.feed.channel", &ChannelRecord{})
+	util.RegisterType("org.xcvr.lrc.media", &MediaRecord{})
+	util.RegisterType("org.xcvr.lrc.message", &MessageRecord{})
+	util.RegisterType("org.xcvr.lrc.signet", &SignetRecord{})
+}
+
+func (t *MediaRecord_Media) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if t.Image != nil {
+		return t.Image.MarshalCBOR(w)
+	}
+	return fmt.Errorf("cannot cbor marshal empty union")
+}
+
+func (t *MediaRecord_Media) UnmarshalCBOR(r io.Reader) error {
+	typ, b, err := util.CborTypeExtractReader(r)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "org.xcvr.lrc.image":
+		t.Image = new(Image)
+		return t.Image.UnmarshalCBOR(bytes.NewReader(b))
+	default:
+		return nil
+	}
+}
@@ -1,16 +1,27 @@
+// Code generated by cmd/lexgen. DO NOT EDIT.
+
 package lex
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/bluesky-social/indigo/lex/util"
 )
 
-func init() {
-	util.RegisterType("org.xcvr.actor.profile", &ProfileRecord{})
-	util.RegisterType("org.xcvr.feed.channel", &ChannelRecord{})
-	util.RegisterType("org.xcvr.lrc.message", &MessageRecord{})
-	util.RegisterType("org.xcvr.lrc.signet", &SignetRecord{})
+// ProfileView is the profileView definition in the org.xcvr.actor.defs lexicon
+type ProfileView struct {
+	Did         string  `json:"did"`
+	Handle      *string `json:"handle,omitempty"`
+	DisplayName *string `json:"displayName,omitempty"`
+	Status      *string `json:"status,omitempty"`
+	Color       *uint64 `json:"color,omitempty"`
+	Avatar      *string `json:"avatar,omitempty"`
 }
 
+// ProfileRecord is the main definition in the org.xcvr.actor.profile lexicon
+//
+// an xcvr profile, there is only ever one per repo
 type ProfileRecord struct {
 	LexiconTypeID string        `json:"$type,const=org.xcvr.actor.profile" cborgen:"$type,const=org.xcvr.actor.profile"`
 	DisplayName   *string       `json:"displayName,omitempty" cborgen:"displayName,omitempty"`
@@ -20,6 +31,15 @@ type ProfileRecord struct {
 	Color         *uint64       `json:"color,omitempty" cborgen:"color,omitempty"`
 }
 
+// ResolveChannel_Output is the output definition in the org.xcvr.actor.resolveChannel lexicon
+type ResolveChannel_Output struct {
+	URL string  `json:"url"`
+	URI *string `json:"uri,omitempty"`
+}
+
+// ChannelRecord is the main definition in the org.xcvr.feed.channel lexicon
+//
+// a channel that lives on an lrc server
 type ChannelRecord struct {
 	LexiconTypeID string  `json:"$type,const=org.xcvr.feed.channel" cborgen:"$type,const=org.xcvr.feed.channel"`
 	Title         string  `json:"title" cborgen:"title"`
@@ -28,6 +48,95 @@ type ChannelRecord struct {
 	Host          string  `json:"host" cborgen:"host"`
 }
 
+// ChannelView is the channelView definition in the org.xcvr.feed.defs lexicon
+type ChannelView struct {
+	URI       string       `json:"uri"`
+	Host      string       `json:"host"`
+	Creator   *ProfileView `json:"creator"`
+	Title     string       `json:"title"`
+	Topic     *string      `json:"topic,omitempty"`
+	CreatedAt string       `json:"createdAt"`
+}
+
+// MessageView is the messageView definition in the org.xcvr.lrc.defs lexicon
+type MessageView struct {
+	LexiconTypeID string       `json:"$type,const=org.xcvr.lrc.defs#messageView"`
+	URI           string       `json:"uri"`
+	Author        *ProfileView `json:"author"`
+	Body          string       `json:"body"`
+	Nick          *string      `json:"nick,omitempty"`
+	Color         *uint64      `json:"color,omitempty"`
+	SignetURI     string       `json:"signetURI"`
+	PostedAt      string       `json:"postedAt"`
+}
+
+// SignetView is the signetView definition in the org.xcvr.lrc.defs lexicon
+type SignetView struct {
+	LexiconTypeID string `json:"$type,const=org.xcvr.lrc.defs#signetView"`
+	URI           string `json:"uri"`
+	IssuerHandle  string `json:"issuerHandle"`
+	ChannelURI    string `json:"channelURI"`
+	LrcID         uint64 `json:"lrcID"`
+	AuthorHandle  string `json:"authorHandle"`
+	StartedAt     string `json:"startedAt"`
+}
+
+// Image is the main definition in the org.xcvr.lrc.image lexicon
+type Image struct {
+	LexiconTypeID string        `json:"$type,const=org.xcvr.lrc.image" cborgen:"$type,const=org.xcvr.lrc.image"`
+	Alt           string        `json:"alt" cborgen:"alt"`
+	AspectRatio   *AspectRatio  `json:"aspectRatio,omitempty" cborgen:"aspectRatio,omitempty"`
+	Image         *util.LexBlob `json:"image,omitempty" cborgen:"image,omitempty"`
+}
+
+// AspectRatio is the aspectRatio definition in the org.xcvr.lrc.image lexicon
+type AspectRatio struct {
+	Width  uint64 `json:"width" cborgen:"width"`
+	Height uint64 `json:"height" cborgen:"height"`
+}
+
+// MediaRecord is the main definition in the org.xcvr.lrc.media lexicon
+//
+// media that was sent over lrc, signed by the signet that was issued for it
+type MediaRecord struct {
+	LexiconTypeID string             `json:"$type,const=org.xcvr.lrc.media" cborgen:"$type,const=org.xcvr.lrc.media"`
+	SignetURI     string             `json:"signetURI" cborgen:"signetURI"`
+	Media         *MediaRecord_Media `json:"media" cborgen:"media"`
+	Nick          *string            `json:"nick,omitempty" cborgen:"nick,omitempty"`
+	Color         *uint64            `json:"color,omitempty" cborgen:"color,omitempty"`
+	PostedAt      string             `json:"postedAt" cborgen:"postedAt"`
+}
+
+// MediaRecord_Media holds exactly one of its variants
+type MediaRecord_Media struct {
+	Image *Image
+}
+
+func (t *MediaRecord_Media) MarshalJSON() ([]byte, error) {
+	if t.Image != nil {
+		t.Image.LexiconTypeID = "org.xcvr.lrc.image"
+		return json.Marshal(t.Image)
+	}
+	return nil, fmt.Errorf("cannot marshal empty union")
+}
+
+func (t *MediaRecord_Media) UnmarshalJSON(b []byte) error {
+	typ, err := util.TypeExtract(b)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "org.xcvr.lrc.image":
+		t.Image = new(Image)
+		return json.Unmarshal(b, t.Image)
+	default:
+		return nil
+	}
+}
+
+// MessageRecord is the main definition in the org.xcvr.lrc.message lexicon
+//
+// a message that was sent over lrc, signed by the signet that was issued for it
 type MessageRecord struct {
 	LexiconTypeID string  `json:"$type,const=org.xcvr.lrc.message" cborgen:"$type,const=org.xcvr.lrc.message"`
 	SignetURI     string  `json:"signetURI" cborgen:"signetURI"`
@@ -37,35 +146,48 @@ type MessageRecord struct {
 	PostedAt      string  `json:"postedAt" cborgen:"postedAt"`
 }
 
+// SignetRecord is the main definition in the org.xcvr.lrc.signet lexicon
+//
+// issued by a channel's host to attest that an lrc id belongs to a handle
 type SignetRecord struct {
 	LexiconTypeID string  `json:"$type,const=org.xcvr.lrc.signet" cborgen:"$type,const=org.xcvr.lrc.signet"`
 	ChannelURI    string  `json:"channelURI" cborgen:"channelURI"`
-	LRCID         uint64  `json:"lrcID" cborgen:"lrcID"`
+	LrcID         uint64  `json:"lrcID" cborgen:"lrcID"`
 	AuthorHandle  string  `json:"authorHandle" cborgen:"authorHandle"`
 	StartedAt     *string `json:"startedAt,omitempty" cborgen:"startedAt,omitempty"`
 }
 
-type MediaRecord struct {
-	LexiconTypeID string  `json:"$type,const=org.xcvr.lrc.media" cborgen:"$type,const=org.xcvr.lrc.media"`
-	SignetURI     string  `json:"signetURI" cborgen:"signetURI"`
-	Media         Media   `json:"media" cborgen:"media"`
-	Nick          *string `json:"nick,omitempty" cborgen:"nick,omitempty"`
-	Color         *uint64 `json:"color,omitempty" cborgen:"color,omitempty"`
-	PostedAt      string  `json:"postedAt" cborgen:"postedAt"`
-}
-
-type Media struct {
-	Image *Image
+// SubscribeLexStream_Message holds exactly one of its variants
+type SubscribeLexStream_Message struct {
+	SignetView  *SignetView
+	MessageView *MessageView
 }
 
-type Image struct {
-	LexiconTypeID string           `json:"$type,const=org.xcvr.lrc.image" cborgen:"$type,const=org.xcvr.lrc.image"`
-	Alt           string           `json:"alt" cborgen:"alt"`
-	AspectRatio   *AspectRatio     `json:"aspectRatio,omitempty" cborgen:"aspectRatio,omitempty"`
-	Image         *util.BlobSchema `json:"image,omitempty" cborgen:"image,omitempty"`
+func (t *SubscribeLexStream_Message) MarshalJSON() ([]byte, error) {
+	if t.SignetView != nil {
+		t.SignetView.LexiconTypeID = "org.xcvr.lrc.defs#signetView"
+		return json.Marshal(t.SignetView)
+	}
+	if t.MessageView != nil {
+		t.MessageView.LexiconTypeID = "org.xcvr.lrc.defs#messageView"
+		return json.Marshal(t.MessageView)
+	}
+	return nil, fmt.Errorf("cannot marshal empty union")
 }
 
-type AspectRatio struct {
-	Height int64 `json:"height" cborgen:"height"`
-	Width  int64 `json:"width" cborgen:"width"`
+func (t *SubscribeLexStream_Message) UnmarshalJSON(b []byte) error {
+	typ, err := util.TypeExtract(b)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "org.xcvr.lrc.defs#signetView":
+		t.SignetView = new(SignetView)
+		return json.Unmarshal(b, t.SignetView)
+	case "org.xcvr.lrc.defs#messageView":
+		t.MessageView = new(MessageView)
+		return json.Unmarshal(b, t.MessageView)
+	default:
+		return nil
+	}
 }
@@ -0,0 +1,215 @@
+package lex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/rivo/uniseg"
+)
+
+var (
+	ErrRequired         = errors.New("is required")
+	ErrTooLong          = errors.New("is too long")
+	ErrTooManyGraphemes = errors.New("has too many graphemes")
+	ErrBadDatetime      = errors.New("is not a valid datetime")
+	ErrBadATURI         = errors.New("is not a valid at-uri")
+	ErrBadHandle        = errors.New("is not a valid handle")
+	ErrOutOfRange       = errors.New("is out of range")
+	ErrBadBlob          = errors.New("is not an acceptable blob")
+)
+
+// ValidationError describes a single field of a record that doesn't satisfy
+// the constraints in its lexicon. Err is one of the Err* sentinels so callers
+// can errors.Is on the kind of problem
+type ValidationError struct {
+	NSID  string
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.NSID, e.Field, e.Err.Error())
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validator is implemented by every record type, createRecord paths should
+// call Validate before sending anything to a pds
+type Validator interface {
+	Validate() error
+}
+
+const (
+	maxColor = 16777215
+
+	maxDisplayNameLength    = 640
+	maxDisplayNameGraphemes = 64
+	maxNickLength           = 16
+	maxStatusLength         = 6400
+	maxStatusGraphemes      = 640
+	maxAvatarSize           = 1000000
+
+	maxTitleLength    = 640
+	maxTitleGraphemes = 64
+	maxTopicLength    = 2560
+	maxTopicGraphemes = 256
+	maxHostLength     = 253
+
+	maxBodyLength    = 20000
+	maxBodyGraphemes = 2000
+
+	maxAltLength    = 10000
+	maxAltGraphemes = 1000
+	maxImageSize    = 1000000
+)
+
+var acceptedImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+type validator struct {
+	nsid string
+	errs []error
+}
+
+func (v *validator) fail(field string, err error) {
+	v.errs = append(v.errs, &ValidationError{v.nsid, field, err})
+}
+
+func (v *validator) required(field string, s string) bool {
+	if s == "" {
+		v.fail(field, ErrRequired)
+		return false
+	}
+	return true
+}
+
+func (v *validator) text(field string, s *string, maxLength int, maxGraphemes int) {
+	if s == nil {
+		return
+	}
+	if len(*s) > maxLength {
+		v.fail(field, ErrTooLong)
+		return
+	}
+	if maxGraphemes > 0 && uniseg.GraphemeClusterCount(*s) > maxGraphemes {
+		v.fail(field, ErrTooManyGraphemes)
+	}
+}
+
+func (v *validator) datetime(field string, s *string) {
+	if s == nil {
+		return
+	}
+	if _, err := syntax.ParseDatetime(*s); err != nil {
+		v.fail(field, ErrBadDatetime)
+	}
+}
+
+func (v *validator) aturi(field string, s string) {
+	if _, err := syntax.ParseATURI(s); err != nil {
+		v.fail(field, ErrBadATURI)
+	}
+}
+
+func (v *validator) color(field string, c *uint64) {
+	if c != nil && *c > maxColor {
+		v.fail(field, ErrOutOfRange)
+	}
+}
+
+func (v *validator) blob(field string, mimeType string, size int64, maxSize int64, accept []string) {
+	if size > maxSize || !slices.Contains(accept, mimeType) {
+		v.fail(field, ErrBadBlob)
+	}
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+func (r *ProfileRecord) Validate() error {
+	v := validator{nsid: "org.xcvr.actor.profile"}
+	v.text("displayName", r.DisplayName, maxDisplayNameLength, maxDisplayNameGraphemes)
+	v.text("defaultNick", r.DefaultNick, maxNickLength, 0)
+	v.text("status", r.Status, maxStatusLength, maxStatusGraphemes)
+	if r.Avatar != nil {
+		v.blob("avatar", r.Avatar.MimeType, r.Avatar.Size, maxAvatarSize, acceptedImageTypes)
+	}
+	v.color("color", r.Color)
+	return v.err()
+}
+
+func (r *ChannelRecord) Validate() error {
+	v := validator{nsid: "org.xcvr.feed.channel"}
+	if v.required("title", r.Title) {
+		v.text("title", &r.Title, maxTitleLength, maxTitleGraphemes)
+	}
+	v.text("topic", r.Topic, maxTopicLength, maxTopicGraphemes)
+	if v.required("createdAt", r.CreatedAt) {
+		v.datetime("createdAt", &r.CreatedAt)
+	}
+	if v.required("host", r.Host) {
+		v.text("host", &r.Host, maxHostLength, 0)
+	}
+	return v.err()
+}
+
+func (r *MessageRecord) Validate() error {
+	v := validator{nsid: "org.xcvr.lrc.message"}
+	if v.required("signetURI", r.SignetURI) {
+		v.aturi("signetURI", r.SignetURI)
+	}
+	v.text("body", &r.Body, maxBodyLength, maxBodyGraphemes)
+	v.text("nick", r.Nick, maxNickLength, 0)
+	v.color("color", r.Color)
+	if v.required("postedAt", r.PostedAt) {
+		v.datetime("postedAt", &r.PostedAt)
+	}
+	return v.err()
+}
+
+func (r *SignetRecord) Validate() error {
+	v := validator{nsid: "org.xcvr.lrc.signet"}
+	if v.required("channelURI", r.ChannelURI) {
+		v.aturi("channelURI", r.ChannelURI)
+	}
+	if r.LrcID > math.MaxUint32 {
+		v.fail("lrcID", ErrOutOfRange)
+	}
+	if v.required("authorHandle", r.AuthorHandle) {
+		if _, err := syntax.ParseHandle(r.AuthorHandle); err != nil {
+			v.fail("authorHandle", ErrBadHandle)
+		}
+	}
+	v.datetime("startedAt", r.StartedAt)
+	return v.err()
+}
+
+func (r *MediaRecord) Validate() error {
+	v := validator{nsid: "org.xcvr.lrc.media"}
+	if v.required("signetURI", r.SignetURI) {
+		v.aturi("signetURI", r.SignetURI)
+	}
+	if r.Media == nil || r.Media.Image == nil {
+		v.fail("media", ErrRequired)
+	} else {
+		img := r.Media.Image
+		v.text("media.alt", &img.Alt, maxAltLength, maxAltGraphemes)
+		if img.AspectRatio != nil && (img.AspectRatio.Width < 1 || img.AspectRatio.Height < 1) {
+			v.fail("media.aspectRatio", ErrOutOfRange)
+		}
+		if img.Image != nil {
+			v.blob("media.image", img.Image.MimeType, img.Image.Size, maxImageSize, acceptedImageTypes)
+		}
+	}
+	v.text("nick", r.Nick, maxNickLength, 0)
+	v.color("color", r.Color)
+	if v.required("postedAt", r.PostedAt) {
+		v.datetime("postedAt", &r.PostedAt)
+	}
+	return v.err()
+}
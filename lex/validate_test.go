@@ -0,0 +1,283 @@
+package lex
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+type lexiconProperty struct {
+	MaxLength    *int     `json:"maxLength"`
+	MaxGraphemes *int     `json:"maxGraphemes"`
+	Maximum      *int     `json:"maximum"`
+	MaxSize      *int     `json:"maxSize"`
+	Accept       []string `json:"accept"`
+}
+
+type lexiconObject struct {
+	Properties map[string]lexiconProperty `json:"properties"`
+}
+
+type lexiconDef struct {
+	lexiconObject
+	Record *lexiconObject `json:"record"`
+}
+
+type lexiconDoc struct {
+	ID   string                `json:"id"`
+	Defs map[string]lexiconDef `json:"defs"`
+}
+
+// loadLexicons reads every lexicon under lexicons/ by id
+func loadLexicons(t *testing.T) map[string]lexiconDoc {
+	t.Helper()
+	docs := make(map[string]lexiconDoc)
+	err := filepath.WalkDir("../lexicons", func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc lexiconDoc
+		err = json.Unmarshal(data, &doc)
+		if err != nil {
+			return err
+		}
+		docs[doc.ID] = doc
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+// property finds a property like org.xcvr.lrc.message#main.body
+func property(t *testing.T, docs map[string]lexiconDoc, ref string) lexiconProperty {
+	t.Helper()
+	id, rest, _ := strings.Cut(ref, "#")
+	def, name, _ := strings.Cut(rest, ".")
+	d, ok := docs[id].Defs[def]
+	if !ok {
+		t.Fatalf("no lexicon def %s#%s", id, def)
+	}
+	obj := d.lexiconObject
+	if d.Record != nil {
+		obj = *d.Record
+	}
+	p, ok := obj.Properties[name]
+	if !ok {
+		t.Fatalf("no property %s", ref)
+	}
+	return p
+}
+
+// TestConstantsMatchLexicons keeps the limits in validate.go from drifting
+// away from the lexicons they were copied out of
+func TestConstantsMatchLexicons(t *testing.T) {
+	docs := loadLexicons(t)
+	tests := []struct {
+		ref          string
+		maxLength    int
+		maxGraphemes int
+	}{
+		{"org.xcvr.actor.profile#main.displayName", maxDisplayNameLength, maxDisplayNameGraphemes},
+		{"org.xcvr.actor.profile#main.defaultNick", maxNickLength, 0},
+		{"org.xcvr.actor.profile#main.status", maxStatusLength, maxStatusGraphemes},
+		{"org.xcvr.feed.channel#main.title", maxTitleLength, maxTitleGraphemes},
+		{"org.xcvr.feed.channel#main.topic", maxTopicLength, maxTopicGraphemes},
+		{"org.xcvr.feed.channel#main.host", maxHostLength, 0},
+		{"org.xcvr.lrc.message#main.body", maxBodyLength, maxBodyGraphemes},
+		{"org.xcvr.lrc.message#main.nick", maxNickLength, 0},
+		{"org.xcvr.lrc.media#main.nick", maxNickLength, 0},
+		{"org.xcvr.lrc.image#main.alt", maxAltLength, maxAltGraphemes},
+	}
+	for _, tt := range tests {
+		p := property(t, docs, tt.ref)
+		if p.MaxLength == nil || *p.MaxLength != tt.maxLength {
+			t.Errorf("%s: maxLength is %v in the lexicon, %d in validate.go", tt.ref, deref(p.MaxLength), tt.maxLength)
+		}
+		if deref(p.MaxGraphemes) != tt.maxGraphemes {
+			t.Errorf("%s: maxGraphemes is %v in the lexicon, %d in validate.go", tt.ref, deref(p.MaxGraphemes), tt.maxGraphemes)
+		}
+	}
+	for _, ref := range []string{
+		"org.xcvr.actor.profile#main.color",
+		"org.xcvr.lrc.message#main.color",
+		"org.xcvr.lrc.media#main.color",
+	} {
+		if p := property(t, docs, ref); deref(p.Maximum) != maxColor {
+			t.Errorf("%s: maximum is %d in the lexicon, %d in validate.go", ref, deref(p.Maximum), maxColor)
+		}
+	}
+	blobs := []struct {
+		ref     string
+		maxSize int
+	}{
+		{"org.xcvr.actor.profile#main.avatar", maxAvatarSize},
+		{"org.xcvr.lrc.image#main.image", maxImageSize},
+	}
+	for _, tt := range blobs {
+		p := property(t, docs, tt.ref)
+		if deref(p.MaxSize) != tt.maxSize {
+			t.Errorf("%s: maxSize is %d in the lexicon, %d in validate.go", tt.ref, deref(p.MaxSize), tt.maxSize)
+		}
+		if !slices.Equal(p.Accept, acceptedImageTypes) {
+			t.Errorf("%s: accepts %v in the lexicon, %v in validate.go", tt.ref, p.Accept, acceptedImageTypes)
+		}
+	}
+}
+
+func deref(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+func TestValidate(t *testing.T) {
+	const (
+		signet = "at://did:plc:abc/org.xcvr.lrc.signet/3k"
+		now    = "2026-10-18T12:00:00Z"
+	)
+	message := func(edit func(*MessageRecord)) Validator {
+		r := &MessageRecord{SignetURI: signet, Body: "hi", Nick: ptr("me"), Color: ptr(uint64(0xffffff)), PostedAt: now}
+		edit(r)
+		return r
+	}
+	profile := func(edit func(*ProfileRecord)) Validator {
+		r := &ProfileRecord{DisplayName: ptr("me"), Status: ptr("here"), Avatar: &util.LexBlob{MimeType: "image/png", Size: 10}}
+		edit(r)
+		return r
+	}
+	channel := func(edit func(*ChannelRecord)) Validator {
+		r := &ChannelRecord{Title: "lounge", Topic: ptr("hi"), CreatedAt: now, Host: "xcvr.org"}
+		edit(r)
+		return r
+	}
+	signetRecord := func(edit func(*SignetRecord)) Validator {
+		r := &SignetRecord{ChannelURI: "at://did:plc:abc/org.xcvr.feed.channel/3k", LrcID: 7, AuthorHandle: "me.xcvr.org", StartedAt: ptr(now)}
+		edit(r)
+		return r
+	}
+	media := func(edit func(*MediaRecord)) Validator {
+		r := &MediaRecord{
+			SignetURI: signet,
+			Media: &MediaRecord_Media{Image: &Image{
+				Alt:         "a cat",
+				AspectRatio: &AspectRatio{Width: 4, Height: 3},
+				Image:       &util.LexBlob{MimeType: "image/jpeg", Size: 10},
+			}},
+			PostedAt: now,
+		}
+		edit(r)
+		return r
+	}
+	long := func(n int) *string {
+		return ptr(strings.Repeat("a", n))
+	}
+	tests := []struct {
+		name  string
+		r     Validator
+		field string
+		err   error
+	}{
+		{"message", message(func(r *MessageRecord) {}), "", nil},
+		{"message body bytes", message(func(r *MessageRecord) { r.Body = *long(maxBodyLength + 1) }), "body", ErrTooLong},
+		{"message body graphemes", message(func(r *MessageRecord) { r.Body = *long(maxBodyGraphemes + 1) }), "body", ErrTooManyGraphemes},
+		{"message multibyte body", message(func(r *MessageRecord) { r.Body = strings.Repeat("é", maxBodyGraphemes) }), "", nil},
+		{"message nick", message(func(r *MessageRecord) { r.Nick = long(maxNickLength + 1) }), "nick", ErrTooLong},
+		{"message color", message(func(r *MessageRecord) { r.Color = ptr(uint64(0x1000000)) }), "color", ErrOutOfRange},
+		{"message datetime", message(func(r *MessageRecord) { r.PostedAt = "yesterday" }), "postedAt", ErrBadDatetime},
+		{"message at-uri", message(func(r *MessageRecord) { r.SignetURI = "https://xcvr.org/signet" }), "signetURI", ErrBadATURI},
+		{"message no signet", message(func(r *MessageRecord) { r.SignetURI = "" }), "signetURI", ErrRequired},
+		{"message no postedAt", message(func(r *MessageRecord) { r.PostedAt = "" }), "postedAt", ErrRequired},
+
+		{"profile", profile(func(r *ProfileRecord) {}), "", nil},
+		{"empty profile", profile(func(r *ProfileRecord) { *r = ProfileRecord{} }), "", nil},
+		{"profile display name bytes", profile(func(r *ProfileRecord) { r.DisplayName = long(maxDisplayNameLength + 1) }), "displayName", ErrTooLong},
+		{"profile display name graphemes", profile(func(r *ProfileRecord) { r.DisplayName = long(maxDisplayNameGraphemes + 1) }), "displayName", ErrTooManyGraphemes},
+		{"profile status bytes", profile(func(r *ProfileRecord) { r.Status = long(maxStatusLength + 1) }), "status", ErrTooLong},
+		{"profile status graphemes", profile(func(r *ProfileRecord) { r.Status = long(maxStatusGraphemes + 1) }), "status", ErrTooManyGraphemes},
+		{"profile nick", profile(func(r *ProfileRecord) { r.DefaultNick = long(maxNickLength + 1) }), "defaultNick", ErrTooLong},
+		{"profile color", profile(func(r *ProfileRecord) { r.Color = ptr(uint64(maxColor + 1)) }), "color", ErrOutOfRange},
+		{"profile avatar type", profile(func(r *ProfileRecord) { r.Avatar.MimeType = "image/bmp" }), "avatar", ErrBadBlob},
+		{"profile avatar size", profile(func(r *ProfileRecord) { r.Avatar.Size = maxAvatarSize + 1 }), "avatar", ErrBadBlob},
+
+		{"channel", channel(func(r *ChannelRecord) {}), "", nil},
+		{"channel title bytes", channel(func(r *ChannelRecord) { r.Title = *long(maxTitleLength + 1) }), "title", ErrTooLong},
+		{"channel title graphemes", channel(func(r *ChannelRecord) { r.Title = *long(maxTitleGraphemes + 1) }), "title", ErrTooManyGraphemes},
+		{"channel topic bytes", channel(func(r *ChannelRecord) { r.Topic = long(maxTopicLength + 1) }), "topic", ErrTooLong},
+		{"channel topic graphemes", channel(func(r *ChannelRecord) { r.Topic = long(maxTopicGraphemes + 1) }), "topic", ErrTooManyGraphemes},
+		{"channel host", channel(func(r *ChannelRecord) { r.Host = *long(maxHostLength + 1) }), "host", ErrTooLong},
+		{"channel datetime", channel(func(r *ChannelRecord) { r.CreatedAt = "2026-13-01T00:00:00Z" }), "createdAt", ErrBadDatetime},
+		{"channel no title", channel(func(r *ChannelRecord) { r.Title = "" }), "title", ErrRequired},
+		{"channel no createdAt", channel(func(r *ChannelRecord) { r.CreatedAt = "" }), "createdAt", ErrRequired},
+		{"channel no host", channel(func(r *ChannelRecord) { r.Host = "" }), "host", ErrRequired},
+
+		{"signet", signetRecord(func(r *SignetRecord) {}), "", nil},
+		{"signet at-uri", signetRecord(func(r *SignetRecord) { r.ChannelURI = "xcvr.org/lounge" }), "channelURI", ErrBadATURI},
+		{"signet lrc id", signetRecord(func(r *SignetRecord) { r.LrcID = math.MaxUint32 + 1 }), "lrcID", ErrOutOfRange},
+		{"signet handle", signetRecord(func(r *SignetRecord) { r.AuthorHandle = "not a handle" }), "authorHandle", ErrBadHandle},
+		{"signet datetime", signetRecord(func(r *SignetRecord) { r.StartedAt = ptr("now") }), "startedAt", ErrBadDatetime},
+		{"signet no channel", signetRecord(func(r *SignetRecord) { r.ChannelURI = "" }), "channelURI", ErrRequired},
+		{"signet no handle", signetRecord(func(r *SignetRecord) { r.AuthorHandle = "" }), "authorHandle", ErrRequired},
+
+		{"media", media(func(r *MediaRecord) {}), "", nil},
+		{"media alt bytes", media(func(r *MediaRecord) { r.Media.Image.Alt = *long(maxAltLength + 1) }), "media.alt", ErrTooLong},
+		{"media alt graphemes", media(func(r *MediaRecord) { r.Media.Image.Alt = *long(maxAltGraphemes + 1) }), "media.alt", ErrTooManyGraphemes},
+		{"media aspect ratio", media(func(r *MediaRecord) { r.Media.Image.AspectRatio.Height = 0 }), "media.aspectRatio", ErrOutOfRange},
+		{"media image size", media(func(r *MediaRecord) { r.Media.Image.Image.Size = maxImageSize + 1 }), "media.image", ErrBadBlob},
+		{"media nick", media(func(r *MediaRecord) { r.Nick = long(maxNickLength + 1) }), "nick", ErrTooLong},
+		{"media color", media(func(r *MediaRecord) { r.Color = ptr(uint64(maxColor + 1)) }), "color", ErrOutOfRange},
+		{"media datetime", media(func(r *MediaRecord) { r.PostedAt = "18/10/2026" }), "postedAt", ErrBadDatetime},
+		{"media at-uri", media(func(r *MediaRecord) { r.SignetURI = "at://" }), "signetURI", ErrBadATURI},
+		{"media no media", media(func(r *MediaRecord) { r.Media = nil }), "media", ErrRequired},
+		{"media no signet", media(func(r *MediaRecord) { r.SignetURI = "" }), "signetURI", ErrRequired},
+	}
+	for _, tt := range tests {
+		err := tt.r.Validate()
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: %v isn't a ValidationError", tt.name, err)
+			continue
+		}
+		if verr.Field != tt.field {
+			t.Errorf("%s: failed on %s, want %s", tt.name, verr.Field, tt.field)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: %v isn't %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// TestValidateEveryField checks that one bad record reports all of its
+// problems rather than only the first
+func TestValidateEveryField(t *testing.T) {
+	r := &MessageRecord{Body: strings.Repeat("a", maxBodyLength+1), Color: ptr(uint64(maxColor + 1)), PostedAt: "later"}
+	err := r.Validate()
+	for _, want := range []error{ErrRequired, ErrTooLong, ErrOutOfRange, ErrBadDatetime} {
+		if !errors.Is(err, want) {
+			t.Errorf("%v doesn't include %v", err, want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
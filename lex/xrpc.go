@@ -0,0 +1,45 @@
+// Code generated by cmd/lexgen. DO NOT EDIT.
+
+package lex
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// ResolveChannel calls the xrpc query "org.xcvr.actor.resolveChannel"
+//
+// resolves a channel record to the websocket that hosts it
+func ResolveChannel(ctx context.Context, c util.LexClient, did string, rkey string) (*ResolveChannel_Output, error) {
+	var out ResolveChannel_Output
+	params := map[string]any{}
+	params["did"] = did
+	params["rkey"] = rkey
+	if err := c.LexDo(ctx, util.Query, "", "org.xcvr.actor.resolveChannel", params, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetChannels calls the xrpc query "org.xcvr.feed.getChannels"
+//
+// lists the channels that the appview knows about. the appview answers with a bare array rather than an object
+func GetChannels(ctx context.Context, c util.LexClient) ([]*ChannelView, error) {
+	var out []*ChannelView
+	params := map[string]any{}
+	if err := c.LexDo(ctx, util.Query, "", "org.xcvr.feed.getChannels", params, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubscribeLexStreamURL builds the websocket url for the xrpc subscription
+// "org.xcvr.lrc.subscribeLexStream", host should include the ws:// or wss:// scheme
+func SubscribeLexStreamURL(host string, uri string) string {
+	params := url.Values{}
+	params.Set("uri", fmt.Sprint(uri))
+	return fmt.Sprintf("%s/xrpc/org.xcvr.lrc.subscribeLexStream?%s", host, params.Encode())
+}
@@ -0,0 +1,413 @@
+// Package lrcclient talks to lrc.v1 channels. a Client keeps a materialized
+// Store of the channel's messages, delivers everything that happens in the
+// channel on its Events channel and turns whole drafts into the edits that
+// lrc sends, so that bots and other clients don't need to deal with events or
+// utf-16 offsets themselves
+package lrcclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"github.com/rachel-mp4/ttyxcvr/record"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrClosed is returned when using a Client after it has been closed
+var ErrClosed = errors.New("lrc client is closed")
+
+const (
+	// sendBuffer is how many encoded events can wait to be written before
+	// Send and friends block
+	sendBuffer = 256
+	// eventBuffer is how many events can wait to be received before the
+	// client stops reading from the channel
+	eventBuffer = 256
+)
+
+// Config is who a Client is and what it keeps track of
+type Config struct {
+	Nick       *string
+	ExternalID *string
+	Color      *uint32
+	// Scrollback is how many finished messages the Store keeps, 0 keeps
+	// them all
+	Scrollback int
+	// EditWindow is how long edits to a draft are held before being sent,
+	// so that everything typed in that time goes out as one EditBatch. 0
+	// sends every edit at once
+	EditWindow time.Duration
+	// Tap sees every encoded event that is sent or received, for recording
+	Tap func(record.Direction, []byte)
+}
+
+type Client struct {
+	conn   *websocket.Conn
+	store  *Store
+	tap    func(record.Direction, []byte)
+	out    chan []byte
+	events chan Event
+	done   chan struct{}
+
+	// draftmu is held while sending the events for a draft so that they go
+	// out in order. draft is what we have sent of the message we are
+	// typing, drafting is set while there is one
+	draftmu  sync.Mutex
+	draft    string
+	drafting bool
+	// window is the EditWindow, timer is set while one is open and latest
+	// is what the draft says by now if pending. windows counts the windows
+	// opened, so that a tick knows if it is still for the open one
+	window  time.Duration
+	timer   *time.Timer
+	windows uint64
+	latest  string
+	pending bool
+
+	mu    sync.Mutex
+	topic *string
+	err   error
+}
+
+// Connect dials the lrc websocket at url, over wss unless url says otherwise
+func Connect(ctx context.Context, url string, cfg Config) (*Client, error) {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		Subprotocols:     []string{"lrc.v1"},
+	}
+	if !strings.HasPrefix(url, "ws://") && !strings.HasPrefix(url, "wss://") {
+		url = "wss://" + url
+	}
+	conn, _, err := dialer.DialContext(ctx, url, http.Header{})
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, cfg), nil
+}
+
+// New starts a client on an lrc websocket that is already open, introducing
+// it with a Set and asking for the topic
+func New(conn *websocket.Conn, cfg Config) *Client {
+	c := &Client{
+		conn:   conn,
+		store:  NewStore(cfg.Scrollback),
+		tap:    cfg.Tap,
+		out:    make(chan []byte, sendBuffer),
+		events: make(chan Event, eventBuffer),
+		done:   make(chan struct{}),
+		window: cfg.EditWindow,
+	}
+	go c.write()
+	go c.read()
+	c.SetIdentity(cfg.Nick, cfg.ExternalID, cfg.Color)
+	// setting a field of a Get asks for it, what it is set to doesn't matter
+	bep := "bep"
+	c.send(&lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: &bep}}})
+	return c
+}
+
+// Events delivers everything that happens in the channel, after it has been
+// applied to the Store. it is closed once the connection is gone, Err says
+// why. events stop being read from the channel while nobody is receiving them
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+func (c *Client) Store() *Store {
+	return c.store
+}
+
+// Topic is the channel's topic, once it has answered our Get
+func (c *Client) Topic() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.topic == nil {
+		return "", false
+	}
+	return *c.topic, true
+}
+
+// Err returns why the client stopped, if it has
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) SetIdentity(nick *string, externalID *string, color *uint32) error {
+	return c.send(&lrcpb.Event{Msg: &lrcpb.Event_Set{Set: &lrcpb.Set{Nick: nick, ExternalID: externalID, Color: color}}})
+}
+
+// Edit makes the message we are typing say text, starting one if we aren't
+// typing yet. only what changed is sent. with an EditWindow, the first edit
+// goes out at once and the ones after it are sent together once the window
+// is over
+func (c *Client) Edit(text string) error {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	if c.window <= 0 {
+		return c.edit(text)
+	}
+	if c.timer != nil {
+		c.latest = text
+		c.pending = true
+		return nil
+	}
+	err := c.edit(text)
+	if err != nil {
+		return err
+	}
+	c.open()
+	return nil
+}
+
+// SetEditWindow changes the EditWindow, sending anything that was being held
+func (c *Client) SetEditWindow(d time.Duration) error {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	c.window = d
+	return c.flush()
+}
+
+// open starts an edit window if we are typing
+func (c *Client) open() {
+	if !c.drafting {
+		return
+	}
+	c.windows++
+	n := c.windows
+	c.timer = time.AfterFunc(c.window, func() { c.tick(n) })
+}
+
+// tick ends edit window n, sending what changed during it and opening
+// another one if anything did
+func (c *Client) tick(n uint64) {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	if c.timer == nil || c.windows != n {
+		// it was flushed while we were waiting for the lock
+		return
+	}
+	c.timer = nil
+	if !c.pending {
+		return
+	}
+	c.pending = false
+	if c.edit(c.latest) == nil {
+		c.open()
+	}
+}
+
+// flush closes the edit window, sending whatever was held in it
+func (c *Client) flush() error {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if !c.pending {
+		return nil
+	}
+	c.pending = false
+	return c.edit(c.latest)
+}
+
+// edit sends what it takes to make the draft say text
+func (c *Client) edit(text string) error {
+	if !c.drafting {
+		if text == "" {
+			return nil
+		}
+		err := c.send(&lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{}}})
+		if err != nil {
+			return err
+		}
+		c.drafting = true
+		c.draft = text
+		return c.send(&lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Body: text}}})
+	}
+	batch := EditBatch(c.draft, text)
+	if batch == nil {
+		return nil
+	}
+	c.draft = text
+	return c.send(&lrcpb.Event{Msg: &lrcpb.Event_Editbatch{Editbatch: batch}})
+}
+
+// Draft returns what we have sent of the message we are typing, which
+// doesn't include edits that are being held
+func (c *Client) Draft() (string, bool) {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	return c.draft, c.drafting
+}
+
+// Publish finishes the message we are typing, after sending any edits that
+// are still being held
+func (c *Client) Publish() error {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	return c.publish()
+}
+
+func (c *Client) publish() error {
+	err := c.flush()
+	if err != nil {
+		return err
+	}
+	if !c.drafting {
+		return nil
+	}
+	c.drafting = false
+	c.draft = ""
+	return c.send(&lrcpb.Event{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{}}})
+}
+
+// Retract takes back the message we are typing, deleting everything in it
+// before publishing it so that nothing of it is left
+func (c *Client) Retract() error {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	// what was being held doesn't matter, it is all going away
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.pending = false
+	if !c.drafting {
+		return nil
+	}
+	if batch := EditBatch(c.draft, ""); batch != nil {
+		err := c.send(&lrcpb.Event{Msg: &lrcpb.Event_Editbatch{Editbatch: batch}})
+		if err != nil {
+			return err
+		}
+	}
+	return c.publish()
+}
+
+// Send sends text as a whole message in one go, publishing whatever we were
+// typing first
+func (c *Client) Send(text string) error {
+	c.draftmu.Lock()
+	defer c.draftmu.Unlock()
+	err := c.publish()
+	if err != nil || text == "" {
+		return err
+	}
+	events := []*lrcpb.Event{
+		{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{}}},
+		{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Body: text}}},
+		{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{}}},
+	}
+	for _, e := range events {
+		err := c.send(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send queues e to be written
+func (c *Client) send(e *lrcpb.Event) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// out has room to spare long after we have stopped
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+// Close hangs up. events that were queued but not yet written may be lost
+func (c *Client) Close() error {
+	deadline := time.Now().Add(time.Second)
+	err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	c.stop(ErrClosed)
+	if errors.Is(err, websocket.ErrCloseSent) {
+		return nil
+	}
+	return err
+}
+
+// stop shuts the client down, remembering the first reason it was given
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	c.conn.Close()
+}
+
+func (c *Client) write() {
+	for {
+		select {
+		case data := <-c.out:
+			if c.tap != nil {
+				c.tap(record.Out, data)
+			}
+			err := c.conn.WriteMessage(websocket.BinaryMessage, data)
+			if err != nil {
+				c.stop(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// read applies everything the channel sends to the store and passes it on.
+// events that can't be applied, like an insert without an id, are still
+// passed on without a Message, for whoever keeps messages of their own
+func (c *Client) read() {
+	defer close(c.events)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.stop(err)
+			return
+		}
+		if c.tap != nil {
+			c.tap(record.In, data)
+		}
+		var e lrcpb.Event
+		err = proto.Unmarshal(data, &e)
+		if err != nil {
+			continue
+		}
+		ev, err := c.store.Apply(&e)
+		if err != nil {
+			ev.Message = nil
+		}
+		if ev.Kind == KindGet && e.GetGet().Topic != nil {
+			c.mu.Lock()
+			c.topic = e.GetGet().Topic
+			c.mu.Unlock()
+		}
+		select {
+		case c.events <- ev:
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package lrcclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	lrcpb "github.com/rachel-mp4/lrcproto/gen/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// channel is a fake lrc channel that hands over everything a client sends it
+// and sends the client whatever it is given
+type channel struct {
+	got  chan *lrcpb.Event
+	give chan *lrcpb.Event
+}
+
+func newChannel(t *testing.T) (*channel, string) {
+	ch := &channel{got: make(chan *lrcpb.Event, 64), give: make(chan *lrcpb.Event)}
+	upgrader := websocket.Upgrader{Subprotocols: []string{"lrc.v1"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			for e := range ch.give {
+				data, _ := proto.Marshal(e)
+				conn.WriteMessage(websocket.BinaryMessage, data)
+			}
+		}()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var e lrcpb.Event
+			if proto.Unmarshal(data, &e) == nil {
+				ch.got <- &e
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		close(ch.give)
+		srv.Close()
+	})
+	return ch, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// kinds reads n events from the channel and names what each one was
+func (ch *channel) kinds(t *testing.T, n int) []string {
+	t.Helper()
+	kinds := make([]string, 0, n)
+	for range n {
+		select {
+		case e := <-ch.got:
+			kind := "other"
+			switch e.Msg.(type) {
+			case *lrcpb.Event_Set:
+				kind = "set"
+			case *lrcpb.Event_Get:
+				kind = "get"
+			case *lrcpb.Event_Init:
+				kind = "init"
+			case *lrcpb.Event_Insert:
+				kind = "insert:" + e.GetInsert().Body
+			case *lrcpb.Event_Editbatch:
+				kind = "batch"
+			case *lrcpb.Event_Pub:
+				kind = "pub"
+			}
+			kinds = append(kinds, kind)
+		case <-time.After(time.Second):
+			t.Fatalf("got %v, then nothing", kinds)
+		}
+	}
+	return kinds
+}
+
+func (ch *channel) quiet(t *testing.T, d time.Duration) {
+	t.Helper()
+	select {
+	case e := <-ch.got:
+		t.Errorf("got %v, want nothing", e)
+	case <-time.After(d):
+	}
+}
+
+func connect(t *testing.T, url string, cfg Config) *Client {
+	t.Helper()
+	c, err := Connect(context.Background(), url, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func equal(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClientOrder(t *testing.T) {
+	ch, url := newChannel(t)
+	c := connect(t, url, Config{Nick: ptr("ann")})
+	equal(t, ch.kinds(t, 2), "set", "get")
+
+	c.Edit("")
+	c.Edit("h")
+	c.Edit("hi")
+	c.Edit("hi")
+	c.Publish()
+	c.Publish()
+	equal(t, ch.kinds(t, 4), "init", "insert:h", "batch", "pub")
+
+	c.Edit("half")
+	c.Send("whole")
+	equal(t, ch.kinds(t, 6), "init", "insert:half", "pub", "init", "insert:whole", "pub")
+
+	c.Edit("gone")
+	c.Retract()
+	equal(t, ch.kinds(t, 4), "init", "insert:gone", "batch", "pub")
+	ch.quiet(t, 50*time.Millisecond)
+}
+
+func TestClientEditWindow(t *testing.T) {
+	ch, url := newChannel(t)
+	c := connect(t, url, Config{EditWindow: 100 * time.Millisecond})
+	ch.kinds(t, 2)
+
+	// the first edit goes out at once and the rest wait for the window
+	c.Edit("h")
+	c.Edit("he")
+	c.Edit("hel")
+	equal(t, ch.kinds(t, 2), "init", "insert:h")
+	ch.quiet(t, 50*time.Millisecond)
+	equal(t, ch.kinds(t, 1), "batch")
+	if draft, _ := c.Draft(); draft != "hel" {
+		t.Errorf("draft is %q after the window, want hel", draft)
+	}
+
+	// publishing sends what is held first
+	c.Edit("hell")
+	c.Edit("hello")
+	c.Publish()
+	equal(t, ch.kinds(t, 2), "batch", "pub")
+	ch.quiet(t, 150*time.Millisecond)
+}
+
+func TestClientEvents(t *testing.T) {
+	ch, url := newChannel(t)
+	c := connect(t, url, Config{})
+	ch.kinds(t, 2)
+	ch.give <- initEvent(1, "bob")
+	ch.give <- insertEvent(1, 0, "hey")
+	// a set for a message the store doesn't have still comes through
+	ch.give <- setEvent(9, "cat")
+	ch.give <- &lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: ptr("the topic")}}}
+	want := []struct {
+		kind    Kind
+		message bool
+	}{{KindInit, true}, {KindEdit, true}, {KindSet, false}, {KindGet, false}}
+	for _, w := range want {
+		select {
+		case ev := <-c.Events():
+			if ev.Kind != w.kind || (ev.Message != nil) != w.message {
+				t.Errorf("got kind %v with message %v, want kind %v", ev.Kind, ev.Message, w.kind)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+	if m, ok := c.Store().Get(1); !ok || m.Text != "hey" {
+		t.Errorf("store has %v", m)
+	}
+	if topic, ok := c.Topic(); !ok || topic != "the topic" {
+		t.Errorf("topic is %q", topic)
+	}
+	c.Close()
+	if _, ok := <-c.Events(); ok {
+		t.Error("events are still open after closing")
+	}
+	if err := c.Edit("x"); err != ErrClosed {
+		t.Errorf("editing after closing got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package lrcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+// PasswordClient publishes and deletes org.xcvr.lrc.message records in a
+// repo that it logged into with an app password. it is safe to use from more
+// than one goroutine, requests are made one at a time
+type PasswordClient struct {
+	// mu is held around every request, they share the Authorization header
+	// and a refresh swaps the tokens out from under them
+	mu         sync.Mutex
+	xrpc       *client.APIClient
+	accessjwt  *string
+	refreshjwt *string
+	did        *string
+}
+
+// Login looks up where handle's repo lives and logs into it with an app
+// password
+func Login(ctx context.Context, handle string, secret string) (*PasswordClient, error) {
+	hdl, err := syntax.ParseHandle(handle)
+	if err != nil {
+		return nil, errors.New("handle failed to parse: " + err.Error())
+	}
+	id, err := identity.DefaultDirectory().LookupHandle(ctx, hdl)
+	if err != nil {
+		return nil, errors.New("handle failed to loopup: " + err.Error())
+	}
+	xrpc := NewPasswordClient(id.DID.String(), id.PDSEndpoint())
+	err = xrpc.CreateSession(ctx, handle, secret)
+	if err != nil {
+		return nil, err
+	}
+	return xrpc, nil
+}
+
+func NewPasswordClient(did string, host string) *PasswordClient {
+	return &PasswordClient{
+		xrpc: client.NewAPIClient(host),
+		did:  &did,
+	}
+}
+
+func (c *PasswordClient) CreateSession(ctx context.Context, identity string, secret string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	input := atproto.ServerCreateSession_Input{
+		Identifier: identity,
+		Password:   secret,
+	}
+	var out atproto.ServerCreateSession_Output
+	err := c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.server.createSession", nil, input, &out)
+	if err != nil {
+		return errors.New("I couldn't create a session: " + err.Error())
+	}
+	c.accessjwt = &out.AccessJwt
+	c.refreshjwt = &out.RefreshJwt
+	return nil
+}
+
+// DID is the did of the repo that records are published to
+func (c *PasswordClient) DID() string {
+	return *c.did
+}
+
+func (c *PasswordClient) RefreshSession(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshSession(ctx)
+}
+
+func (c *PasswordClient) refreshSession(ctx context.Context) error {
+	c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.refreshjwt))
+	var out atproto.ServerRefreshSession_Output
+	err := c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.server.refreshSession", nil, nil, &out)
+	if err != nil {
+		return errors.New("failed to refresh session! " + err.Error())
+	}
+	c.accessjwt = &out.AccessJwt
+	c.refreshjwt = &out.RefreshJwt
+	return nil
+}
+
+func (c *PasswordClient) CreateXCVRMessage(message *lex.MessageRecord, ctx context.Context) (cid string, uri string, err error) {
+	input := atproto.RepoCreateRecord_Input{
+		Collection: "org.xcvr.lrc.message",
+		Repo:       *c.did,
+		Record:     &util.LexiconTypeDecoder{Val: message},
+	}
+	return c.createMyRecord(input, ctx)
+}
+
+func (c *PasswordClient) DeleteXCVRMessage(rkey string, cid *string, ctx context.Context) error {
+	input := atproto.RepoDeleteRecord_Input{
+		Collection: "org.xcvr.lrc.message",
+		Repo:       *c.did,
+		Rkey:       rkey,
+		SwapRecord: cid,
+	}
+	return c.deleteMyRecord(input, ctx)
+}
+
+func (c *PasswordClient) deleteMyRecord(input atproto.RepoDeleteRecord_Input, ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessjwt == nil {
+		return errors.New("must create a session first")
+	}
+	c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
+	var out atproto.RepoDeleteRecord_Output
+	err := c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.deleteRecord", nil, input, &out)
+	if err != nil {
+		err1 := err.Error()
+		err = c.refreshSession(ctx)
+		if err != nil {
+			return errors.New(fmt.Sprintf("failed to refresh session while deleting %s! first %s then %s", input.Collection, err1, err.Error()))
+		}
+		c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
+		out = atproto.RepoDeleteRecord_Output{}
+		err = c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.deleteRecord", nil, input, &out)
+		if err != nil {
+			return errors.New(fmt.Sprintf("not good, failed to delete %s after failing then refreshing session! first %s then %s", input.Collection, err1, err.Error()))
+		}
+	}
+	return nil
+}
+
+func (c *PasswordClient) createMyRecord(input atproto.RepoCreateRecord_Input, ctx context.Context) (cid string, uri string, err error) {
+	if v, ok := input.Record.Val.(lex.Validator); ok {
+		err = v.Validate()
+		if err != nil {
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessjwt == nil {
+		err = errors.New("must create a session first")
+		return
+	}
+	c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
+	var out atproto.RepoCreateRecord_Output
+	err = c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.createRecord", nil, input, &out)
+	if err != nil {
+		err1 := err.Error()
+		err = c.refreshSession(ctx)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("failed to refresh session while creating %s! first %s then %s", input.Collection, err1, err.Error()))
+			return
+		}
+		c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
+		out = atproto.RepoCreateRecord_Output{}
+		err = c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.createRecord", nil, input, &out)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("not good, failed to create %s after failing then refreshing session! first %s then %s", input.Collection, err1, err.Error()))
+			return
+		}
+		cid = out.Cid
+		uri = out.Uri
+		return
+	}
+	cid = out.Cid
+	uri = out.Uri
+	return
+}
@@ -0,0 +1,108 @@
+package lrcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+// pds is a fake pds whose access tokens expire every few records, so that
+// publishes keep needing a refresh
+type pds struct {
+	mu      sync.Mutex
+	session int
+	expired bool
+	created int
+	bad     []string
+}
+
+func (p *pds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	auth := r.Header.Get("Authorization")
+	switch r.URL.Path {
+	case "/xrpc/com.atproto.server.createSession":
+	case "/xrpc/com.atproto.server.refreshSession":
+		if auth != fmt.Sprintf("Bearer refresh%d", p.session) {
+			p.bad = append(p.bad, "refresh with "+auth)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "InvalidToken"})
+			return
+		}
+		p.session++
+		p.expired = false
+	case "/xrpc/com.atproto.repo.createRecord":
+		if p.expired || auth != fmt.Sprintf("Bearer access%d", p.session) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "ExpiredToken"})
+			return
+		}
+		p.created++
+		if p.created%3 == 0 {
+			p.expired = true
+		}
+		json.NewEncoder(w).Encode(map[string]string{"uri": fmt.Sprintf("at://did:plc:me/org.xcvr.lrc.message/%d", p.created), "cid": "cid"})
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"accessJwt":  fmt.Sprintf("access%d", p.session),
+		"refreshJwt": fmt.Sprintf("refresh%d", p.session),
+		"handle":     "me.test",
+		"did":        "did:plc:me",
+	})
+}
+
+// TestPasswordClientConcurrent publishes from many goroutines at once, the
+// way the outbox and bot host do, each one has to come out with its own
+// record and without tripping over another's refresh
+func TestPasswordClientConcurrent(t *testing.T) {
+	p := &pds{}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	c := NewPasswordClient("did:plc:me", srv.URL)
+	err := c.CreateSession(context.Background(), "me.test", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 24
+	uris := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lmr := lex.MessageRecord{
+				SignetURI: "at://did:plc:me/org.xcvr.lrc.signet/1",
+				Body:      fmt.Sprint(i),
+				PostedAt:  syntax.DatetimeNow().String(),
+			}
+			_, uri, err := c.CreateXCVRMessage(&lmr, context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			uris <- uri
+		}()
+	}
+	wg.Wait()
+	close(uris)
+	seen := make(map[string]bool)
+	for uri := range uris {
+		if seen[uri] {
+			t.Errorf("%s was handed out twice", uri)
+		}
+		seen[uri] = true
+	}
+	if len(p.bad) > 0 {
+		t.Errorf("pds saw %v", p.bad)
+	}
+}
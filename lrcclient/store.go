@@ -0,0 +1,216 @@
+package lrcclient
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rachel-mp4/lrcproto/gen/go"
+)
+
+// Message is a message in a channel as it stands after every event about it
+// so far
+type Message struct {
+	ID         uint32
+	Nick       *string
+	ExternalID *string
+	Color      *uint32
+	// Active is set until the message is pub'd
+	Active bool
+	Text   string
+	// Mine is set on the messages that were started by this connection
+	Mine bool
+}
+
+type Kind int
+
+const (
+	// KindOther is everything that isn't about a message or the channel,
+	// like Ping, Mute or Kick
+	KindOther Kind = iota
+	KindInit
+	// KindEdit is an Insert, Delete or EditBatch
+	KindEdit
+	KindPub
+	KindSet
+	// KindGet is the channel answering a Get
+	KindGet
+)
+
+// Event is something that happened in a channel. Raw is the event as it
+// arrived and Message is a copy of the message it was about after applying
+// it. a Pub only has a Message the first time, so that a message is only
+// ever finished once. there is no Message for events that couldn't be
+// applied, or that were about a message the store no longer keeps, like a
+// Set for one that was pruned past Scrollback
+type Event struct {
+	Kind    Kind
+	Raw     *lrcpb.Event
+	Message *Message
+}
+
+// Store materializes a channel's events into messages. it is safe to use
+// from several goroutines
+type Store struct {
+	mu    sync.Mutex
+	msgs  map[uint32]*Message
+	order []uint32
+	limit int
+}
+
+// NewStore makes a store that keeps up to limit finished messages, dropping
+// the oldest ones past that. a limit of 0 keeps everything
+func NewStore(limit int) *Store {
+	return &Store{
+		msgs:  make(map[uint32]*Message),
+		limit: limit,
+	}
+}
+
+// Get returns a copy of the message with the given id
+func (s *Store) Get(id uint32) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.msgs[id]
+	if !ok {
+		return Message{}, false
+	}
+	return *m, true
+}
+
+// Messages returns a copy of every message in the order they were started
+func (s *Store) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := make([]Message, 0, len(s.order))
+	for _, id := range s.order {
+		msgs = append(msgs, *s.msgs[id])
+	}
+	return msgs
+}
+
+func (s *Store) add(id uint32, m *Message) *Message {
+	m.ID = id
+	if _, ok := s.msgs[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.msgs[id] = m
+	return m
+}
+
+// getOrAdd returns the message with the given id, starting an empty one if
+// we missed its init
+func (s *Store) getOrAdd(id uint32) *Message {
+	if m, ok := s.msgs[id]; ok {
+		return m
+	}
+	return s.add(id, &Message{Active: true})
+}
+
+// prune drops the oldest finished messages until the store is within its
+// limit
+func (s *Store) prune() {
+	if s.limit <= 0 || len(s.order) <= s.limit {
+		return
+	}
+	excess := len(s.order) - s.limit
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if excess > 0 && !s.msgs[id].Active {
+			delete(s.msgs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	clear(s.order[len(kept):])
+	s.order = kept
+}
+
+// Apply updates the store with e and describes what it did
+func (s *Store) Apply(e *lrcpb.Event) (Event, error) {
+	if e == nil {
+		return Event{}, errors.New("no event")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev := Event{Kind: KindOther, Raw: e}
+	var m *Message
+	switch msg := e.Msg.(type) {
+	case *lrcpb.Event_Init:
+		ev.Kind = KindInit
+		if msg.Init == nil || msg.Init.Id == nil {
+			return ev, errors.New("init without an id")
+		}
+		m = s.add(*msg.Init.Id, &Message{
+			Nick:       msg.Init.Nick,
+			ExternalID: msg.Init.ExternalID,
+			Color:      msg.Init.Color,
+			Active:     true,
+			Mine:       msg.Init.GetEchoed(),
+		})
+	case *lrcpb.Event_Insert:
+		ev.Kind = KindEdit
+		if msg.Insert == nil || msg.Insert.Id == nil {
+			return ev, errors.New("insert without an id")
+		}
+		m = s.getOrAdd(*msg.Insert.Id)
+		m.Text = InsertAtUTF16Index(m.Text, msg.Insert.Utf16Index, msg.Insert.Body)
+	case *lrcpb.Event_Delete:
+		ev.Kind = KindEdit
+		if msg.Delete == nil || msg.Delete.Id == nil {
+			return ev, errors.New("delete without an id")
+		}
+		m = s.getOrAdd(*msg.Delete.Id)
+		m.Text = DeleteBetweenUTF16Indices(m.Text, msg.Delete.Utf16Start, msg.Delete.Utf16End)
+	case *lrcpb.Event_Editbatch:
+		ev.Kind = KindEdit
+		if e.Id == nil {
+			return ev, errors.New("edit batch without an id")
+		}
+		m = s.getOrAdd(*e.Id)
+		for _, edit := range msg.Editbatch.GetEdits() {
+			switch edit := edit.Edit.(type) {
+			case *lrcpb.Edit_Insert:
+				if edit.Insert == nil {
+					return ev, errors.New("no insert")
+				}
+				m.Text = InsertAtUTF16Index(m.Text, edit.Insert.Utf16Index, edit.Insert.Body)
+			case *lrcpb.Edit_Delete:
+				if edit.Delete == nil {
+					return ev, errors.New("no delete")
+				}
+				m.Text = DeleteBetweenUTF16Indices(m.Text, edit.Delete.Utf16Start, edit.Delete.Utf16End)
+			}
+		}
+	case *lrcpb.Event_Pub:
+		ev.Kind = KindPub
+		if msg.Pub == nil || msg.Pub.Id == nil {
+			return ev, errors.New("pub without an id")
+		}
+		m = s.msgs[*msg.Pub.Id]
+		if m == nil || !m.Active {
+			return ev, nil
+		}
+		m.Active = false
+		defer s.prune()
+	case *lrcpb.Event_Set:
+		ev.Kind = KindSet
+		if e.Id == nil || msg.Set == nil {
+			return ev, nil
+		}
+		m = s.msgs[*e.Id]
+		if m == nil {
+			return ev, nil
+		}
+		m.Nick = msg.Set.Nick
+		m.ExternalID = msg.Set.ExternalID
+		m.Color = msg.Set.Color
+	case *lrcpb.Event_Get:
+		ev.Kind = KindGet
+	}
+	if m != nil {
+		c := *m
+		ev.Message = &c
+	}
+	return ev, nil
+}
@@ -0,0 +1,151 @@
+package lrcclient
+
+import (
+	"slices"
+	"testing"
+
+	lrcpb "github.com/rachel-mp4/lrcproto/gen/go"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func initEvent(id uint32, nick string) *lrcpb.Event {
+	return &lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{Id: &id, Nick: &nick}}}
+}
+
+func insertEvent(id uint32, at uint32, body string) *lrcpb.Event {
+	return &lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Id: &id, Utf16Index: at, Body: body}}}
+}
+
+func deleteEvent(id uint32, start uint32, end uint32) *lrcpb.Event {
+	return &lrcpb.Event{Msg: &lrcpb.Event_Delete{Delete: &lrcpb.Delete{Id: &id, Utf16Start: start, Utf16End: end}}}
+}
+
+func batchEvent(id uint32, from string, to string) *lrcpb.Event {
+	return &lrcpb.Event{Id: &id, Msg: &lrcpb.Event_Editbatch{Editbatch: EditBatch(from, to)}}
+}
+
+func pubEvent(id uint32) *lrcpb.Event {
+	return &lrcpb.Event{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{Id: &id}}}
+}
+
+func setEvent(id uint32, nick string) *lrcpb.Event {
+	return &lrcpb.Event{Id: &id, Msg: &lrcpb.Event_Set{Set: &lrcpb.Set{Nick: &nick}}}
+}
+
+// text is what a message says and whether it is still being typed, for
+// comparing stores
+type text struct {
+	nick   string
+	text   string
+	active bool
+}
+
+func texts(msgs []Message) []text {
+	out := make([]text, 0, len(msgs))
+	for _, m := range msgs {
+		var nick string
+		if m.Nick != nil {
+			nick = *m.Nick
+		}
+		out = append(out, text{nick, m.Text, m.Active})
+	}
+	return out
+}
+
+func TestStoreApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		events []*lrcpb.Event
+		want   []text
+	}{
+		{
+			name:   "typing",
+			events: []*lrcpb.Event{initEvent(1, "ann"), insertEvent(1, 0, "helo"), insertEvent(1, 3, "l"), deleteEvent(1, 0, 1), insertEvent(1, 0, "j")},
+			want:   []text{{"ann", "jello", true}},
+		},
+		{
+			name:   "edit batch",
+			events: []*lrcpb.Event{initEvent(1, "ann"), insertEvent(1, 0, "a😀b"), batchEvent(1, "a😀b", "a😁b!")},
+			want:   []text{{"ann", "a😁b!", true}},
+		},
+		{
+			name:   "interleaved",
+			events: []*lrcpb.Event{initEvent(1, "ann"), initEvent(2, "bob"), insertEvent(2, 0, "hi"), insertEvent(1, 0, "yo"), pubEvent(2)},
+			want:   []text{{"ann", "yo", true}, {"bob", "hi", false}},
+		},
+		{
+			name:   "missed init",
+			events: []*lrcpb.Event{insertEvent(3, 0, "late"), pubEvent(3)},
+			want:   []text{{"", "late", false}},
+		},
+		{
+			name:   "set",
+			events: []*lrcpb.Event{initEvent(1, "ann"), setEvent(1, "anne")},
+			want:   []text{{"anne", "", true}},
+		},
+		{
+			name:  "scrollback",
+			limit: 2,
+			events: []*lrcpb.Event{
+				initEvent(1, "ann"), pubEvent(1),
+				initEvent(2, "bob"),
+				initEvent(3, "cat"), pubEvent(3),
+				initEvent(4, "dan"), pubEvent(4),
+			},
+			// an active message is never pruned, even past the limit
+			want: []text{{"bob", "", true}, {"dan", "", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(tt.limit)
+			for _, e := range tt.events {
+				_, err := s.Apply(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := texts(s.Messages()); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreApplyEvents(t *testing.T) {
+	s := NewStore(1)
+	tests := []struct {
+		name    string
+		e       *lrcpb.Event
+		kind    Kind
+		message bool
+		err     bool
+	}{
+		{"init", initEvent(1, "ann"), KindInit, true, false},
+		{"insert", insertEvent(1, 0, "hi"), KindEdit, true, false},
+		{"pub", pubEvent(1), KindPub, true, false},
+		{"pub again", pubEvent(1), KindPub, false, false},
+		{"another", initEvent(2, "bob"), KindInit, true, false},
+		{"pub prunes the first", pubEvent(2), KindPub, true, false},
+		{"set for a pruned message", setEvent(1, "anne"), KindSet, false, false},
+		{"insert without an id", &lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Body: "x"}}}, KindEdit, false, true},
+		{"get", &lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: ptr("t")}}}, KindGet, false, false},
+		{"ping", &lrcpb.Event{Msg: &lrcpb.Event_Ping{Ping: &lrcpb.Ping{}}}, KindOther, false, false},
+	}
+	for _, tt := range tests {
+		ev, err := s.Apply(tt.e)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if ev.Kind != tt.kind || (ev.Message != nil) != tt.message || ev.Raw != tt.e {
+			t.Errorf("%s: got kind %v with message %v", tt.name, ev.Kind, ev.Message)
+		}
+	}
+	if _, ok := s.Get(1); ok {
+		t.Error("message 1 is still there past the limit")
+	}
+}
@@ -0,0 +1,137 @@
+package lrcclient
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/rachel-mp4/lrcproto/gen/go"
+)
+
+// DeleteBetweenUTF16Indices removes the utf-16 code units of base from start
+// up to end, which is how lrc counts offsets into a message
+func DeleteBetweenUTF16Indices(base string, start uint32, end uint32) string {
+	if end <= start {
+		return base
+	}
+	runes := []rune(base)
+	baseUTF16Units := utf16.Encode(runes)
+	if uint32(len(baseUTF16Units)) < end {
+		end = uint32(len(baseUTF16Units))
+	}
+	if uint32(len(baseUTF16Units)) < start {
+		return base
+	}
+	result := make([]uint16, 0, uint32(len(baseUTF16Units))+start-end)
+	result = append(result, baseUTF16Units[:start]...)
+	result = append(result, baseUTF16Units[end:]...)
+	resultRunes := utf16.Decode(result)
+	return string(resultRunes)
+}
+
+// InsertAtUTF16Index inserts insert into base at the given utf-16 offset,
+// padding base with spaces if it is too short
+func InsertAtUTF16Index(base string, index uint32, insert string) string {
+	runes := []rune(base)
+	baseUTF16Units := utf16.Encode(runes)
+	if uint32(len(baseUTF16Units)) < index {
+		spacesNeeded := index - uint32(len(baseUTF16Units))
+		padding := strings.Repeat(" ", int(spacesNeeded))
+		base = base + padding
+
+		runes = []rune(base)
+		baseUTF16Units = utf16.Encode(runes)
+	}
+
+	insertRunes := []rune(insert)
+	insertUTF16Units := utf16.Encode(insertRunes)
+	result := make([]uint16, 0, len(baseUTF16Units)+len(insertUTF16Units))
+	result = append(result, baseUTF16Units[:index]...)
+	result = append(result, insertUTF16Units...)
+	result = append(result, baseUTF16Units[index:]...)
+	resultRunes := utf16.Decode(result)
+	return string(resultRunes)
+}
+
+// change is a run of utf-16 units that stay the same followed by the units
+// that are deleted and added after it
+type change struct {
+	keep []uint16
+	del  []uint16
+	add  []uint16
+}
+
+// EditBatch turns the edits that make from into to into the edit batch that
+// a client sends for them, or nil if from and to are the same
+func EditBatch(from string, to string) *lrcpb.EditBatch {
+	changes := surrogateSafe(changesOf(Diff(utf16.Encode([]rune(from)), utf16.Encode([]rune(to)))))
+	idx := uint32(0)
+	batch := make([]*lrcpb.Edit, 0)
+	for _, c := range changes {
+		idx += uint32(len(c.keep))
+		if len(c.del) > 0 {
+			batch = append(batch, &lrcpb.Edit{Edit: &lrcpb.Edit_Delete{Delete: &lrcpb.Delete{Utf16Start: idx, Utf16End: idx + uint32(len(c.del))}}})
+		}
+		if len(c.add) > 0 {
+			body := string(utf16.Decode(c.add))
+			batch = append(batch, &lrcpb.Edit{Edit: &lrcpb.Edit_Insert{Insert: &lrcpb.Insert{Body: body, Utf16Index: idx}}})
+			idx += uint32(len(c.add))
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return &lrcpb.EditBatch{Edits: batch}
+}
+
+// changesOf groups edits into changes, everything deleted and added between
+// two kept runs going together
+func changesOf(edits []Edit) []change {
+	changes := []change{{}}
+	for _, edit := range edits {
+		c := &changes[len(changes)-1]
+		switch edit.EditType {
+		case EditKeep:
+			if len(c.del) > 0 || len(c.add) > 0 {
+				changes = append(changes, change{})
+				c = &changes[len(changes)-1]
+			}
+			c.keep = append(c.keep, edit.Utf16Text...)
+		case EditDel:
+			c.del = append(c.del, edit.Utf16Text...)
+		case EditAdd:
+			c.add = append(c.add, edit.Utf16Text...)
+		}
+	}
+	return changes
+}
+
+// surrogateSafe moves the halves of surrogate pairs that the diff kept into
+// the changes next to them, so that no edit splits a pair. two characters
+// outside the bmp can share a high or a low surrogate, and an insert of half
+// of a pair can't be sent as a string
+func surrogateSafe(changes []change) []change {
+	for i := range changes {
+		c := &changes[i]
+		if i > 0 && len(c.keep) > 0 && isLowSurrogate(c.keep[0]) {
+			prev := &changes[i-1]
+			prev.del = append(prev.del, c.keep[0])
+			prev.add = append(prev.add, c.keep[0])
+			c.keep = c.keep[1:]
+		}
+		if n := len(c.keep); n > 0 && isHighSurrogate(c.keep[n-1]) && (len(c.del) > 0 || len(c.add) > 0) {
+			h := c.keep[n-1]
+			c.del = append([]uint16{h}, c.del...)
+			c.add = append([]uint16{h}, c.add...)
+			c.keep = c.keep[:n-1]
+		}
+	}
+	return changes
+}
+
+func isHighSurrogate(u uint16) bool {
+	return u >= 0xd800 && u < 0xdc00
+}
+
+func isLowSurrogate(u uint16) bool {
+	return u >= 0xdc00 && u < 0xe000
+}
@@ -0,0 +1,97 @@
+package lrcclient
+
+import (
+	"testing"
+
+	lrcpb "github.com/rachel-mp4/lrcproto/gen/go"
+)
+
+// applyBatch does to text what a channel does with batch
+func applyBatch(text string, batch *lrcpb.EditBatch) string {
+	for _, edit := range batch.GetEdits() {
+		switch edit := edit.Edit.(type) {
+		case *lrcpb.Edit_Insert:
+			text = InsertAtUTF16Index(text, edit.Insert.Utf16Index, edit.Insert.Body)
+		case *lrcpb.Edit_Delete:
+			text = DeleteBetweenUTF16Indices(text, edit.Delete.Utf16Start, edit.Delete.Utf16End)
+		}
+	}
+	return text
+}
+
+func TestEditBatch(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"start", "", "hello"},
+		{"append", "hell", "hello"},
+		{"prepend", "ello", "hello"},
+		{"middle", "helo", "hello"},
+		{"delete", "hello", "helo"},
+		{"clear", "hello", ""},
+		{"replace", "hello world", "hello there"},
+		{"rewrite", "abc", "xyz"},
+		{"accents", "héllo", "hëllo wörld"},
+		{"emoji after", "hi", "hi 😀"},
+		{"emoji before", "hi", "😀 hi"},
+		{"emoji removed", "a😀b", "ab"},
+		// 😀 and 😁 share their high surrogate, so a diff over utf-16
+		// units could keep half of the pair
+		{"emoji swapped", "a😀b", "a😁b"},
+		{"emoji next to emoji", "😀😀", "😀😁😀"},
+		// and these share their low surrogate
+		{"emoji with the same low half", "a\U0001F600b", "a\U0001FA00b"},
+		{"flag", "🇬🇧", "🇫🇷"},
+		{"flags", "x🇬🇧🇫🇷y", "x🇫🇷🇬🇧y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := EditBatch(tt.from, tt.to)
+			if got := applyBatch(tt.from, batch); got != tt.to {
+				t.Errorf("applying the batch to %q got %q, want %q", tt.from, got, tt.to)
+			}
+			for _, edit := range batch.GetEdits() {
+				if ins := edit.GetInsert(); ins != nil {
+					for _, r := range ins.Body {
+						if r == '�' {
+							t.Errorf("insert %q has half of a surrogate pair in it", ins.Body)
+						}
+					}
+				}
+			}
+		})
+	}
+	words := []string{"", "a", "😀", "😁", "🙀", "\U0001FA00", "é", "ab😀", "😀ab😁", "🇬🇧", "🇫🇷"}
+	for _, from := range words {
+		for _, to := range words {
+			if got := applyBatch(from, EditBatch(from, to)); got != to {
+				t.Errorf("applying the batch from %q to %q got %q", from, to, got)
+			}
+		}
+	}
+	if batch := EditBatch("same", "same"); batch != nil {
+		t.Errorf("no change got %v, want nil", batch)
+	}
+}
+
+func TestUTF16Indices(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"insert after emoji", InsertAtUTF16Index("😀", 2, "x"), "😀x"},
+		{"insert past the end pads", InsertAtUTF16Index("ab", 4, "x"), "ab  x"},
+		{"delete emoji", DeleteBetweenUTF16Indices("a😀b", 1, 3), "ab"},
+		{"delete past the end", DeleteBetweenUTF16Indices("abc", 1, 10), "a"},
+		{"delete backwards", DeleteBetweenUTF16Indices("abc", 2, 1), "abc"},
+		{"delete after the end", DeleteBetweenUTF16Indices("abc", 5, 6), "abc"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -4,29 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-	"unicode/utf16"
 
-	"github.com/bluesky-social/indigo/api/atproto"
-	"github.com/bluesky-social/indigo/atproto/client"
-	"github.com/bluesky-social/indigo/atproto/identity"
 	"github.com/bluesky-social/indigo/atproto/syntax"
-	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gorilla/websocket"
 	"github.com/rachel-mp4/lrcproto/gen/go"
 	"github.com/rachel-mp4/ttyxcvr/lex"
-	"google.golang.org/protobuf/proto"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/record"
+	"github.com/rachel-mp4/ttyxcvr/xcvr"
 )
 
 const White = lipgloss.Color("#ffffff")
@@ -35,12 +31,14 @@ const Olive = lipgloss.Color("#c6c013")
 const Forest = lipgloss.Color("#034732")
 const Green = lipgloss.Color("#008148")
 const Orange = lipgloss.Color("#ef8a17")
+const Red = lipgloss.Color("#d7263d")
 
 var verySubduedColor = lipgloss.AdaptiveColor{Light: "#DDDADA", Dark: "#3C3C3C"}
 var subduedColor = lipgloss.AdaptiveColor{Light: "#9B9B9B", Dark: "#5C5C5C"}
 var subduedStyle = lipgloss.NewStyle().Foreground(subduedColor)
 
 var PlainStyle = lipgloss.NewStyle().Foreground(White).Background(Black)
+var WarningStyle = lipgloss.NewStyle().Foreground(White).Background(Red).Bold(true)
 
 type txstate int
 
@@ -53,6 +51,9 @@ const (
 	ConnectingToChannel
 	DialingChannel
 	Connected
+	Searching
+	SearchResults
+	History
 )
 
 type txmode int
@@ -69,42 +70,67 @@ type model struct {
 	prompt textinput.Model
 	clm    *channellistmodel
 	cm     *channelmodel
+	sm     *searchmodel
 	gsd    *globalsettingsdata
 }
 
 type channellistmodel struct {
-	channels []Channel
+	channels []*lex.ChannelView
 	list     list.Model
 	gsd      *globalsettingsdata
 }
 
 type channelmodel struct {
-	channel   Channel
+	channel   *lex.ChannelView
 	mode      txmode
 	wsurl     string
-	lrcconn   *websocket.Conn
+	lrc       *lrcclient.Client
 	lexconn   *websocket.Conn
 	cancel    func()
-	vp        viewport.Model
+	vp        transcript
 	draft     textinput.Model
-	msgs      map[uint32]*Message
+	store     *MessageStore
 	myid      *uint32
-	render    []*string
 	sentmsg   *string
 	topic     *string
 	signeturi *string
-	datachan  chan []byte
-	gsd       *globalsettingsdata
+	signets   map[uint32]*lex.SignetView
+	// joined is who we have seen start a message, by nick and handle
+	joined   map[string]bool
+	selected *uint32
+	// retracting is set after a ctrl+u, so that a second one in a row
+	// retracts the message
+	retracting bool
+	// private overrides gsd.private for this channel once it is toggled
+	private *bool
+	// queued is what we sent privately and still need the init of, and
+	// awaiting is what we sent privately and still need the signet of, so
+	// that they can be put in the outbox like live messages are
+	queued   []string
+	awaiting map[uint32]string
+	gsd      *globalsettingsdata
 }
 
 type globalsettingsdata struct {
-	color  *uint32
-	nick   *string
-	handle *string
-	xrpc   *PasswordClient
-	width  int
-	height int
-	state  txstate
+	color          *uint32
+	nick           *string
+	handle         *string
+	xrpc           *lrcclient.PasswordClient
+	xcvr           *xcvr.Client
+	width          int
+	height         int
+	state          txstate
+	hideunverified bool
+	// private keeps drafts to ourselves until they are sent whole
+	private    bool
+	scrollback int
+	// editwindow is how long a draft's edits are held to be sent together
+	editwindow time.Duration
+	outbox     *Outbox
+	archive    *Archive
+	hooks      *Hooks
+	// send delivers messages from background goroutines to the program
+	send func(tea.Msg)
 }
 
 type Message struct {
@@ -113,30 +139,20 @@ type Message struct {
 	color    *uint32
 	active   bool
 	text     string
-	rendered *string
-}
-
-type Profile struct {
-	Type        string  `json:"$type"`
-	Did         string  `json:"did"`
-	Handle      *string `json:"handle,omitempty"`
-	DisplayName *string `json:"displayName,omitempty"`
-	Status      *string `json:"status,omitempty"`
-	Color       *uint32 `json:"color"`
-}
-
-type Channel struct {
-	Type      string    `json:"$type"`
-	URI       string    `json:"uri"`
-	Host      string    `json:"host"`
-	Creator   Profile   `json:"creator"`
-	Title     string    `json:"title"`
-	Topic     *string   `json:"topic,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
+	signet   *lex.SignetView
+	uri      *string
+	cid      *string
+	unsent   bool
+	selected bool
+	outbox   *OutboxEntry
+	id       uint32
+	lines    []string
+	dirty    bool
+	spilled  bool
 }
 
 type ChannelItem struct {
-	channel Channel
+	channel *lex.ChannelView
 }
 
 func (c ChannelItem) Title() string {
@@ -176,13 +192,15 @@ func (d ChannelItemDelegate) Render(w io.Writer, m list.Model, index int, item l
 	if i, ok := item.(ChannelItem); ok {
 		title = i.Title()
 		desc = i.Description()
-		author = fmt.Sprintf("(%s)", renderName(i.channel.Creator.DisplayName, i.channel.Creator.Handle))
+		if i.channel.Creator != nil {
+			author = fmt.Sprintf("(%s)", renderName(i.channel.Creator.DisplayName, i.channel.Creator.Handle))
+			color = color32(i.channel.Creator.Color)
+		}
 		host = subduedStyle.Render(fmt.Sprintf("(hosted on %s)", i.Host()))
 		if desc == "" {
 			desc = subduedStyle.Render("no provided description")
 		}
 		uri = i.URI()
-		color = i.channel.Creator.Color
 	} else {
 		return
 	}
@@ -200,24 +218,57 @@ func (d ChannelItemDelegate) Render(w io.Writer, m list.Model, index int, item l
 	fmt.Fprintf(w, "%s %s\n%s\n%s", title, host, desc, uri)
 }
 
-func initialModel() model {
+func initialModel(send func(tea.Msg)) model {
+	config, cerr := loadConfig()
+	outbox, err := loadOutbox()
+	archive, aerr := openArchive()
+	m, herr := newModel(config, outbox, archive, send)
+	// every one of these is shown, so that a later one doesn't hide an
+	// earlier one
+	var errs []error
+	if err != nil {
+		errs = append(errs, errors.New("couldn't load outbox: "+err.Error()))
+	}
+	if cerr := errors.Join(cerr, herr); cerr != nil {
+		errs = append(errs, errors.New("couldn't load config: "+cerr.Error()))
+	}
+	if aerr != nil {
+		errs = append(errs, errors.New("couldn't open archive: "+aerr.Error()))
+	}
+	if err := errors.Join(errs...); err != nil {
+		out := err.Error()
+		m.cmdout = &out
+	}
+	return m
+}
+
+// newModel starts a model at the splash screen, the error is about the parts
+// of config that couldn't be applied
+func newModel(config *Config, outbox *Outbox, archive *Archive, send func(tea.Msg)) (model, error) {
 	prompt := textinput.New()
 	prompt.Prompt = ":"
 	prompt.Width = 28 //: + prompt.Width + 1 left over for blinky = initialWidth
 	nick := "wanderer"
 	color := uint32(33096)
 	gsd := globalsettingsdata{
-		nick:   &nick,
-		color:  &color,
-		width:  30,
-		height: 20,
-		state:  Splash,
-	}
-	return model{
+		nick:    &nick,
+		color:   &color,
+		width:   30,
+		height:  20,
+		state:   Splash,
+		outbox:  outbox,
+		archive: archive,
+		xcvr:    xcvr.NewClient(xcvr.DefaultHost),
+		send:    send,
+	}
+	err := config.apply(&gsd)
+	m := model{
 		prompt: prompt,
 		gsd:    &gsd,
 	}
+	return m, err
 }
+
 func (m model) Init() tea.Cmd {
 	return nil
 }
@@ -230,49 +281,29 @@ func (m model) updateSplash(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		default:
 			m.gsd.state = GettingChannels
-			return m, GetChannels
+			return m, GetChannels(m.gsd.xcvr)
 		}
 	}
 	return m, nil
 }
 
-func GetChannels() tea.Msg {
-	c := &http.Client{Timeout: 10 * time.Second}
-	res, err := c.Get("http://xcvr.org/xrpc/org.xcvr.feed.getChannels")
-
-	if err != nil {
-		return errMsg{err}
-	}
-	if res.StatusCode != 200 {
-		return errMsg{errors.New(fmt.Sprintf("error getting channels: %d", res.StatusCode))}
-	}
-	decoder := json.NewDecoder(res.Body)
-	var channels []Channel
-	err = decoder.Decode(&channels)
-	if err != nil {
-		return errMsg{err}
+func GetChannels(c *xcvr.Client) tea.Cmd {
+	return func() tea.Msg {
+		channels, err := c.GetChannels(context.Background())
+		if err != nil {
+			return errMsg{errors.New("error getting channels: " + err.Error())}
+		}
+		return channelsMsg{channels}
 	}
-	return channelsMsg{channels}
 }
 
-type channelsMsg struct{ channels []Channel }
+type channelsMsg struct{ channels []*lex.ChannelView }
 
 type errMsg struct{ err error }
 
 func login(handle string, secret string) tea.Cmd {
 	return func() tea.Msg {
-		hdl, err := syntax.ParseHandle(handle)
-		if err != nil {
-			err = errors.New("handle failed to parse: " + err.Error())
-			return errMsg{err}
-		}
-		id, err := identity.DefaultDirectory().LookupHandle(context.Background(), hdl)
-		if err != nil {
-			err = errors.New("handle failed to loopup: " + err.Error())
-			return errMsg{err}
-		}
-		xrpc := NewPasswordClient(id.DID.String(), id.PDSEndpoint())
-		err = xrpc.CreateSession(context.Background(), handle, secret)
+		xrpc, err := lrcclient.Login(context.Background(), handle, secret)
 		if err != nil {
 			return errMsg{err}
 		}
@@ -281,14 +312,40 @@ func login(handle string, secret string) tea.Cmd {
 }
 
 type loggedInMsg struct {
-	xrpc *PasswordClient
+	xrpc *lrcclient.PasswordClient
+}
+
+// redraw picks up changes to the store, staying at the bottom if we were
+// already there
+func (cm *channelmodel) redraw() {
+	ab := cm.vp.AtBottom()
+	cm.vp.Refresh()
+	if ab {
+		cm.vp.GotoBottom()
+	}
+}
+
+// close hangs up on a channel we are leaving and removes its scrollback
+func (cm *channelmodel) close() {
+	if cm == nil {
+		return
+	}
+	if cm.lrc != nil {
+		// a client that has already stopped had its disconnect hook fired
+		// when it was lost
+		if cm.lrc.Err() == nil {
+			cm.hook(&hookEvent{Event: hookDisconnect})
+		}
+		cm.lrc.Close()
+	}
+	cm.store.Close()
 }
 
 func (cm *channelmodel) updateLRCIdentity() {
-	if cm != nil && cm.lrcconn != nil {
-		err := sendSet(cm.datachan, cm.gsd.nick, cm.gsd.handle, cm.gsd.color)
+	if cm != nil && cm.lrc != nil {
+		err := cm.lrc.SetIdentity(cm.gsd.nick, cm.gsd.handle, cm.gsd.color)
 		if err != nil {
-			send(errMsg{err})
+			cm.gsd.send(errMsg{err})
 		}
 	}
 }
@@ -334,11 +391,6 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.gsd.state = Error
 		m.error = &msg.err
 		return m, nil
-	case svMsg:
-		if m.cm != nil && m.cm.myid != nil && msg.signetView.LrcId == *m.cm.myid {
-			m.cm.signeturi = &msg.signetView.URI
-			return m, nil
-		}
 	case dialMsg:
 		m.gsd.state = DialingChannel
 		return m, m.dialingChannel(msg.value)
@@ -349,6 +401,91 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case loggedInMsg:
 		m.gsd.xrpc = msg.xrpc
+		pending := m.gsd.outbox.pending(msg.xrpc.DID())
+		cmds := make([]tea.Cmd, 0, len(pending))
+		for _, e := range pending {
+			cmds = append(cmds, scheduleCmd(m.gsd.xrpc, e))
+		}
+		return m, tea.Batch(cmds...)
+	case publishedMsg:
+		err := m.gsd.outbox.published(msg.id, msg.uri, msg.cid)
+		if err != nil {
+			return m, func() tea.Msg { return errMsg{err} }
+		}
+		m.cm.outboxChanged(m.gsd.outbox.get(msg.id))
+		return m, nil
+	case publishFailedMsg:
+		backoff, again, err := m.gsd.outbox.failed(msg.id, msg.err)
+		if err != nil {
+			return m, func() tea.Msg { return errMsg{err} }
+		}
+		e := m.gsd.outbox.get(msg.id)
+		m.cm.outboxChanged(e)
+		if again {
+			return m, retryAfter(backoff, e)
+		}
+		return m, nil
+	case retryMsg:
+		e := m.gsd.outbox.get(msg.id)
+		s := e.snapshot()
+		if s == nil || s.State != OutboxPending || m.gsd.xrpc == nil || m.gsd.xrpc.DID() != s.Did {
+			return m, nil
+		}
+		// a retry or a login may have published it since this was scheduled
+		if s.inflight || s.gen != msg.gen {
+			return m, nil
+		}
+		return m, scheduleCmd(m.gsd.xrpc, e)
+	case outboxMsg:
+		return m.updateOutbox(msg.value)
+	case unsendMsg:
+		return m.unsend()
+	case retractMsg:
+		if m.gsd.state == Connected && m.cm != nil {
+			err := m.cm.retract()
+			if err != nil {
+				return m, func() tea.Msg { return errMsg{err} }
+			}
+		}
+		return m, nil
+	case exportMsg:
+		return m.export(msg.value)
+	case searchMsg:
+		return m.startSearch(msg.query)
+	case hookFailedMsg:
+		out := fmt.Sprintf("%s hook %q failed: %v", msg.event, msg.command, msg.err)
+		m.cmdout = &out
+		return m, nil
+	case disconnectedMsg:
+		if m.cm != nil && msg.from == m.cm.lrc {
+			m.cm.hook(&hookEvent{Event: hookDisconnect, Error: msg.err.Error()})
+		}
+		m.gsd.state = Error
+		m.error = &msg.err
+		return m, nil
+	case archiveFailedMsg:
+		out := "couldn't archive message, archiving is off until you restart:\n" + msg.err.Error()
+		m.cmdout = &out
+		return m, nil
+	case unsentMsg:
+		if m.cm != nil && m.cm.wsurl == msg.wsurl {
+			if message := m.cm.store.Get(msg.id); message != nil {
+				message.uri = nil
+				message.cid = nil
+				message.outbox = nil
+				message.unsent = true
+				m.cm.store.Touch(msg.id)
+				m.cm.redraw()
+			}
+		}
+		return m, nil
+	case publishInvalidMsg:
+		out := "message was sent but won't be saved to your repo:\n" + msg.err.Error()
+		m.cmdout = &out
+		return m, nil
+	case unsendFailedMsg:
+		out := "couldn't unsend: " + msg.err.Error()
+		m.cmdout = &out
 		return m, nil
 
 	case setMsg:
@@ -387,6 +524,50 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.cm.updateLRCIdentity()
 			return m, nil
+		case "hideunverified", "hu":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return m, nil
+			}
+			m.gsd.hideunverified = b
+			if m.cm != nil {
+				m.cm.store.TouchAll()
+				m.cm.redraw()
+			}
+			return m, nil
+		case "private", "pr":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return m, nil
+			}
+			m.gsd.private = b
+			if m.cm != nil {
+				m.cm.private = nil
+			}
+			return m, nil
+		case "editwindow", "ew":
+			d, err := time.ParseDuration(val)
+			if err != nil || d < 0 {
+				out := "edit window " + val + " isn't a duration like 50ms"
+				m.cmdout = &out
+				return m, nil
+			}
+			m.gsd.editwindow = d
+			if m.cm != nil && m.cm.lrc != nil {
+				m.cm.lrc.SetEditWindow(d)
+			}
+			return m, nil
+		case "scrollback", "sb":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return m, nil
+			}
+			m.gsd.scrollback = n
+			if m.cm != nil {
+				m.cm.store.SetScrollback(n)
+				m.cm.redraw()
+			}
+			return m, nil
 		case "handle", "h", "at", "@":
 			m.gsd.handle = &val
 			if m.cm != nil {
@@ -408,11 +589,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cm.vp.Width = msg.Width
 			m.cm.vp.Height = msg.Height - 2
 			m.cm.draft.Width = m.gsd.width - len(m.cm.draft.Prompt) - 1
-			if m.cm.render != nil {
-				for _, message := range m.cm.msgs {
-					message.renderMessage(msg.Width)
-				}
-				m.cm.vp.SetContent(JoinDeref(m.cm.render, ""))
+			m.cm.store.TouchAll()
+			m.cm.redraw()
+		}
+		if m.sm != nil {
+			m.sm.list.SetSize(msg.Width, msg.Height-1)
+			if m.sm.history != nil {
+				m.sm.history.Width = msg.Width
+				m.sm.history.Height = msg.Height - 1
 			}
 		}
 		return m, nil
@@ -439,6 +623,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case DialingChannel:
 		return m.updateDialingChannel(msg)
 
+	case Searching:
+		return m.updateSearching(msg)
+	case SearchResults:
+		return m.updateSearchResults(msg)
+	case History:
+		return m.updateHistory(msg)
 	case Connected:
 		cm, cmd, err := m.cm.updateConnected(msg)
 		if err != nil {
@@ -456,84 +646,110 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (cm channelmodel) updateConnected(msg tea.Msg) (channelmodel, tea.Cmd, error) {
 	switch msg := msg.(type) {
 	case lrcEvent:
-		if msg.e == nil {
-			return cm, nil, errors.New("nil lrcEvent")
-		}
-		id := msg.e.Id
-		switch msg := msg.e.Msg.(type) {
-		case *lrcpb.Event_Ping:
-			return cm, nil, nil
-		case *lrcpb.Event_Pong:
-			return cm, nil, nil
-		case *lrcpb.Event_Init:
-			err := initMessage(msg.Init, cm.msgs, &cm.render, cm.gsd.width)
-			if err != nil {
-				return cm, nil, err
-			}
-			if msg.Init.Echoed != nil && *msg.Init.Echoed {
-				cm.myid = msg.Init.Id
-			}
-			ab := cm.vp.AtBottom()
-			cm.vp.SetContent(JoinDeref(cm.render, ""))
-			if ab {
-				cm.vp.GotoBottom()
-			}
+		if msg.from != cm.lrc {
 			return cm, nil, nil
-		case *lrcpb.Event_Pub:
-			err := pubMessage(msg.Pub, cm.msgs, cm.gsd.width)
-			if err != nil {
-				return cm, nil, err
-			}
-			cm.vp.SetContent(JoinDeref(cm.render, ""))
-			return cm, nil, err
-		case *lrcpb.Event_Insert:
-			err := insertMessage(msg.Insert, cm.msgs, &cm.render, cm.gsd.width)
-			if err != nil {
-				return cm, nil, err
+		}
+		ev := msg.ev
+		switch ev.Kind {
+		case lrcclient.KindInit, lrcclient.KindEdit, lrcclient.KindSet:
+			if ev.Message == nil {
+				cm.setPruned(ev.Raw)
+				return cm, nil, nil
 			}
-			ab := cm.vp.AtBottom()
-			cm.vp.SetContent(JoinDeref(cm.render, ""))
-			if ab {
-				cm.vp.GotoBottom()
+			init := ev.Kind == lrcclient.KindInit
+			m := cm.store.Update(ev.Message, init)
+			var cmd tea.Cmd
+			if init {
+				// the signet can beat the init here, in which case the svMsg
+				// has already gone by and it's up to us to use it
+				sv := cm.signets[m.id]
+				if ev.Message.Mine {
+					if len(cm.queued) > 0 {
+						body := cm.queued[0]
+						cm.queued = cm.queued[1:]
+						if sv == nil {
+							cm.awaiting[m.id] = body
+						} else {
+							id := m.id
+							var err error
+							cmd, err = cm.keep(&id, sv.URI, body)
+							if err != nil {
+								return cm, nil, err
+							}
+						}
+					} else {
+						cm.myid = &m.id
+						cm.signeturi = nil
+						if sv != nil {
+							cm.signeturi = &sv.URI
+						}
+					}
+				}
+				if sv != nil {
+					m.signet = sv
+				}
+				if !ev.Message.Mine {
+					cm.seen(m)
+				}
 			}
-			return cm, nil, nil
-		case *lrcpb.Event_Delete:
-			err := deleteMessage(msg.Delete, cm.msgs, &cm.render, cm.gsd.width)
-			if err != nil {
-				return cm, nil, err
+			cm.redraw()
+			return cm, cmd, nil
+		case lrcclient.KindPub:
+			if ev.Message == nil {
+				return cm, nil, nil
 			}
-			ab := cm.vp.AtBottom()
-			cm.vp.SetContent(JoinDeref(cm.render, ""))
-			if ab {
-				cm.vp.GotoBottom()
+			m := cm.store.Update(ev.Message, false)
+			cm.redraw()
+			if m.text != "" {
+				cm.hook(&hookEvent{Event: hookPublish, Message: hookMessage(m), Mine: ev.Message.Mine})
+				if !ev.Message.Mine && mentions(m.text, cm.gsd.nick, cm.gsd.handle) {
+					cm.hook(&hookEvent{Event: hookMention, Message: hookMessage(m)})
+				}
 			}
-			return cm, nil, nil
-		case *lrcpb.Event_Mute:
-			return cm, nil, nil
-		case *lrcpb.Event_Unmute:
-			return cm, nil, nil
-		case *lrcpb.Event_Set:
-			return cm, nil, nil
-		case *lrcpb.Event_Get:
-			if msg.Get.Topic != nil {
-				cm.topic = msg.Get.Topic
+			return cm, cm.archive(m), nil
+		case lrcclient.KindGet:
+			if topic := ev.Raw.GetGet().Topic; topic != nil {
+				cm.topic = topic
 			}
 			return cm, nil, nil
-		case *lrcpb.Event_Editbatch:
-			if id == nil {
-				return cm, nil, nil
-			}
-			err := editMessage(*id, msg.Editbatch.Edits, cm.msgs, &cm.render, cm.gsd.width)
+		}
+		return cm, nil, nil
+	case svMsg:
+		sv := msg.signetView
+		lrcid := uint32(sv.LrcID)
+		if cm.myid != nil && lrcid == *cm.myid {
+			cm.signeturi = &sv.URI
+		}
+		var cmd tea.Cmd
+		if body, ok := cm.awaiting[lrcid]; ok {
+			delete(cm.awaiting, lrcid)
+			var err error
+			cmd, err = cm.keep(&lrcid, sv.URI, body)
 			if err != nil {
 				return cm, nil, err
 			}
-			ab := cm.vp.AtBottom()
-			cm.vp.SetContent(JoinDeref(cm.render, ""))
-			if ab {
-				cm.vp.GotoBottom()
+		}
+		cm.signets[lrcid] = sv
+		m := cm.store.Get(lrcid)
+		if m == nil {
+			return cm, cmd, nil
+		}
+		m.signet = sv
+		cm.store.Touch(lrcid)
+		cm.redraw()
+		return cm, cmd, nil
+	case mvMsg:
+		mv := msg.messageView
+		for i := cm.store.Len() - 1; i >= 0; i-- {
+			m := cm.store.At(i)
+			if m.signet != nil && m.signet.URI == mv.SignetURI {
+				m.uri = &mv.URI
+				cm.store.Touch(m.id)
+				cm.redraw()
+				break
 			}
-			return cm, nil, nil
 		}
+		return cm, nil, nil
 	case tea.KeyMsg:
 		switch cm.mode {
 		case Normal:
@@ -549,37 +765,57 @@ func (cm channelmodel) updateConnected(msg tea.Msg) (channelmodel, tea.Cmd, erro
 				cm.mode = Insert
 				cm.draft.CursorEnd()
 				return cm, cm.draft.Focus(), nil
+			case "K":
+				cm.moveSelection(-1)
+				return cm, nil, nil
+			case "J":
+				cm.moveSelection(1)
+				return cm, nil, nil
+			case "esc":
+				cm.clearSelection()
+				return cm, nil, nil
+			case "p":
+				private := !cm.privately()
+				cm.private = &private
+				return cm, nil, nil
 			}
 		case Insert:
+			retracting := cm.retracting
+			cm.retracting = false
 			switch msg.String() {
 			case "esc":
 				cm.mode = Normal
 				cm.draft.Blur()
 				return cm, nil, nil
+			case "ctrl+u":
+				if retracting {
+					return cm, nil, cm.retract()
+				}
+				// the first one still clears what is before the cursor
+				cm.retracting = true
 			case "enter":
 				if cm.sentmsg != nil {
+					var cmd tea.Cmd
 					if cm.gsd.xrpc != nil && cm.signeturi != nil {
-						var color64 *uint64
-						if cm.gsd.color != nil {
-							c64 := uint64(*cm.gsd.color)
-							color64 = &c64
-						}
-						lmr := lex.MessageRecord{
-							SignetURI: *cm.signeturi,
-							Body:      *cm.sentmsg,
-							Nick:      cm.gsd.nick,
-							Color:     color64,
-							PostedAt:  syntax.DatetimeNow().String(),
+						var err error
+						cmd, err = cm.keep(cm.myid, *cm.signeturi, *cm.sentmsg)
+						if err != nil {
+							return cm, nil, err
 						}
-						cm.draft.SetValue("")
-						cm.sentmsg = nil
-						cm.myid = nil
-						cm.signeturi = nil
-						return cm, tea.Batch(sendPub(cm.lrcconn), createMSGCmd(cm.gsd.xrpc, &lmr)), nil
 					}
 					cm.draft.SetValue("")
 					cm.sentmsg = nil
-					return cm, sendPub(cm.lrcconn), nil
+					cm.myid = nil
+					cm.signeturi = nil
+					return cm, cmd, cm.lrc.Publish()
+				}
+				if text := cm.draft.Value(); text != "" {
+					// only a private draft can be left unsent by now
+					cm.draft.SetValue("")
+					if cm.gsd.xrpc != nil {
+						cm.queued = append(cm.queued, text)
+					}
+					return cm, nil, cm.lrc.Send(text)
 				}
 				return cm, nil, nil
 			}
@@ -592,119 +828,93 @@ func (cm channelmodel) updateConnected(msg tea.Msg) (channelmodel, tea.Cmd, erro
 		return cm, cmd, nil
 	case Insert:
 		draft, cmd := cm.draft.Update(msg)
-		if cm.sentmsg == nil && draft.Value() != "" {
+		cm.draft = draft
+		// a message that was already started stays live until it is sent
+		if cm.sentmsg == nil && cm.privately() {
+			return cm, cmd, nil
+		}
+		if (cm.sentmsg == nil && draft.Value() != "") || (cm.sentmsg != nil && *cm.sentmsg != draft.Value()) {
 			nv := draft.Value()
 			cm.sentmsg = &nv
-			cm.draft = draft
-			return cm, tea.Batch(cmd, sendInsert(cm.lrcconn, nv, 0, true)), nil
+			return cm, cmd, cm.lrc.Edit(nv)
 		}
-		if cm.sentmsg != nil && *cm.sentmsg != draft.Value() {
-			draftutf16 := utf16.Encode([]rune(draft.Value()))
-			sentutf16 := utf16.Encode([]rune(*cm.sentmsg))
-			edits := Diff(sentutf16, draftutf16)
-			cm.draft = draft
-			sentmsg := draft.Value()
-			cm.sentmsg = &sentmsg
-			return cm, tea.Batch(cmd, sendEditBatch(cm.datachan, edits)), nil
-		}
-		cm.draft = draft
 		return cm, cmd, nil
 	}
 	return cm, nil, nil
 }
 
-func createMSGCmd(xrpc *PasswordClient, lmr *lex.MessageRecord) tea.Cmd {
-	return func() tea.Msg {
-		_, _, err := xrpc.CreateXCVRMessage(lmr, context.Background())
-		if err != nil {
-			return errMsg{err}
-		}
-		return nil
+// setPruned applies a Set for a message that the client's store has already
+// pruned, but that we still have
+func (cm *channelmodel) setPruned(e *lrcpb.Event) {
+	set := e.GetSet()
+	if set == nil || e.Id == nil {
+		return
 	}
-}
-
-func sendEditBatch(datachan chan []byte, edits []Edit) tea.Cmd {
-	return func() tea.Msg {
-		idx := 0
-		batch := make([]*lrcpb.Edit, 0)
-		for _, edit := range edits {
-			switch edit.EditType {
-			case EditDel:
-				idx2 := idx + len(edit.Utf16Text)
-				evt := makeDelete(uint32(idx), uint32(idx2))
-				edit := lrcpb.Edit{Edit: &lrcpb.Edit_Delete{Delete: evt.GetDelete()}}
-				batch = append(batch, &edit)
-			case EditKeep:
-				idx = idx + len(edit.Utf16Text)
-			case EditAdd:
-				evt := makeInsert(string(utf16.Decode(edit.Utf16Text)), uint32(idx))
-				idx = idx + len(edit.Utf16Text)
-				edit := lrcpb.Edit{Edit: &lrcpb.Edit_Insert{Insert: evt.GetInsert()}}
-				batch = append(batch, &edit)
-			}
-		}
-		evt := lrcpb.Event{Msg: &lrcpb.Event_Editbatch{Editbatch: &lrcpb.EditBatch{Edits: batch}}}
-		data, err := proto.Marshal(&evt)
-		if err != nil {
-			return errMsg{err}
-		}
-		datachan <- data
-		return nil
+	m := cm.store.Get(*e.Id)
+	if m == nil {
+		return
 	}
-}
-
-func sendPub(conn *websocket.Conn) tea.Cmd {
-	return func() tea.Msg {
-		evt := &lrcpb.Event{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{}}}
-		data, err := proto.Marshal(evt)
-		if err != nil {
-			return errMsg{err}
-		}
-		err = conn.WriteMessage(websocket.BinaryMessage, data)
-		if err != nil {
-			return errMsg{err}
-		}
+	m.nick = set.Nick
+	m.handle = set.ExternalID
+	m.color = set.Color
+	cm.store.Touch(m.id)
+	cm.redraw()
+}
+
+// retract takes back the message we are typing, leaving nothing of it in the
+// channel and keeping it out of our repo
+func (cm *channelmodel) retract() error {
+	live := cm.sentmsg != nil
+	cm.draft.SetValue("")
+	cm.sentmsg = nil
+	cm.myid = nil
+	cm.signeturi = nil
+	if !live {
 		return nil
 	}
+	return cm.lrc.Retract()
 }
 
-func makeDelete(start uint32, end uint32) *lrcpb.Event {
-	evt := &lrcpb.Event{Msg: &lrcpb.Event_Delete{Delete: &lrcpb.Delete{Utf16Start: start, Utf16End: end}}}
-	return evt
-}
-
-func makeInsert(body string, idx uint32) *lrcpb.Event {
-	evt := &lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Body: body, Utf16Index: idx}}}
-	return evt
+// privately reports whether drafts in this channel are kept to ourselves
+// until they are sent
+func (cm *channelmodel) privately() bool {
+	if cm.private != nil {
+		return *cm.private
+	}
+	return cm.gsd.private
 }
 
-func sendInsert(conn *websocket.Conn, body string, utf16idx uint32, init bool) tea.Cmd {
-	return func() tea.Msg {
-		if init {
-			evt := &lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{}}}
-			data, err := proto.Marshal(evt)
-			if err != nil {
-				return errMsg{err}
-			}
-			if conn == nil {
-				return nil
-			}
-			err = conn.WriteMessage(websocket.BinaryMessage, data)
-			if err != nil {
-				return errMsg{err}
-			}
-		}
-		evt := &lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Body: body, Utf16Index: utf16idx}}}
-		data, err := proto.Marshal(evt)
-		if err != nil {
-			return errMsg{err}
-		}
-		err = conn.WriteMessage(websocket.BinaryMessage, data)
-		if err != nil {
-			return errMsg{err}
+// keep puts a message we sent in the outbox to be published to our repo,
+// once the channel has signed it
+func (cm *channelmodel) keep(id *uint32, signeturi string, body string) (tea.Cmd, error) {
+	var color64 *uint64
+	if cm.gsd.color != nil {
+		c64 := uint64(*cm.gsd.color)
+		color64 = &c64
+	}
+	lmr := lex.MessageRecord{
+		SignetURI: signeturi,
+		Body:      body,
+		Nick:      cm.gsd.nick,
+		Color:     color64,
+		PostedAt:  syntax.DatetimeNow().String(),
+	}
+	err := lmr.Validate()
+	if err != nil {
+		return func() tea.Msg { return publishInvalidMsg{err} }, nil
+	}
+	e, err := cm.gsd.outbox.add(cm.gsd.xrpc.DID(), cm.wsurl, id, lmr)
+	if err != nil {
+		return nil, err
+	}
+	if id != nil {
+		if m := cm.store.Get(*id); m != nil {
+			m.outbox = e
+			cm.store.Touch(*id)
+			cm.redraw()
 		}
-		return nil
 	}
+	return publishCmd(cm.gsd.xrpc, e), nil
 }
 
 func (m model) evaluateCommand(command string) tea.Cmd {
@@ -730,11 +940,23 @@ func (m model) evaluateCommand(command string) tea.Cmd {
 			if len(parts) != 1 {
 				return dialMsg{parts[1]}
 			}
+		case "outbox":
+			return outboxMsg{parts[1:]}
+		case "unsend":
+			return unsendMsg{}
+		case "retract":
+			return retractMsg{}
+		case "export":
+			return exportMsg{parts[1:]}
+		case "search", "/":
+			return searchMsg{strings.Join(parts[1:], " ")}
 		}
 		return nil
 	}
 }
 
+type retractMsg struct{}
+
 type dialMsg struct {
 	value string
 }
@@ -747,217 +969,92 @@ type setMsg struct {
 	value string
 }
 
-// i think that the type of renders is a bit awkward, but i want deletemessage + friends to just modify the rendered
-// messages slice in place in the event that we create a new msg. i think ideally the way to go is to make a more
-// encapsulated data structure for the map + renders which still allows edits to the messages without requiring
-// rerendering every message
-func deleteMessage(msg *lrcpb.Delete, msgmap map[uint32]*Message, renders *[]*string, width int) error {
-	if msg == nil {
-		return errors.New("no insert")
-	}
-	id := msg.Id
-	if id == nil {
-		return errors.New("no insert id")
-	}
-	m := msgmap[*id]
-	atr := false
-	if m == nil {
-		atr = true
-		renderedDefault := ""
-		m = &Message{
-			nick:     nil,
-			handle:   nil,
-			color:    nil,
-			active:   true,
-			text:     "",
-			rendered: &renderedDefault,
-		}
-		msgmap[*id] = m
-	}
-	start := msg.Utf16Start
-	end := msg.Utf16End
-	m.text = deleteBtwnUTF16Indices(m.text, start, end)
-	m.renderMessage(width)
-	if atr {
-		*renders = append(*renders, m.rendered)
-	}
-	return nil
-}
-func deleteBtwnUTF16Indices(base string, start uint32, end uint32) string {
-	if end <= start {
-		return base
-	}
-	runes := []rune(base)
-	baseUTF16Units := utf16.Encode(runes)
-	if uint32(len(baseUTF16Units)) < end {
-		end = uint32(len(baseUTF16Units))
-	}
-	if uint32(len(baseUTF16Units)) < start {
-		return base
-	}
-	result := make([]uint16, 0, uint32(len(baseUTF16Units))+start-end)
-	result = append(result, baseUTF16Units[:start]...)
-	result = append(result, baseUTF16Units[end:]...)
-	resultRunes := utf16.Decode(result)
-	return string(resultRunes)
-}
-
-func editMessage(id uint32, edits []*lrcpb.Edit, msgmap map[uint32]*Message, renders *[]*string, width int) error {
-	for _, edit := range edits {
-		switch e := edit.Edit.(type) {
-		case *lrcpb.Edit_Insert:
-			ins := e.Insert
-			ins.Id = &id
-			err := insertMessage(ins, msgmap, renders, width)
-			if err != nil {
-				return err
-			}
-		case *lrcpb.Edit_Delete:
-			del := e.Delete
-			del.Id = &id
-			err := deleteMessage(del, msgmap, renders, width)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func insertMessage(msg *lrcpb.Insert, msgmap map[uint32]*Message, renders *[]*string, width int) error {
-	if msg == nil {
-		return errors.New("no insert")
-	}
-	id := msg.Id
-	if id == nil {
-		return errors.New("no insert id")
-	}
-	m := msgmap[*id]
-	atr := false
-	if m == nil {
-		atr = true
-		renderedDefault := ""
-		m = &Message{
-			nick:     nil,
-			handle:   nil,
-			color:    nil,
-			active:   true,
-			text:     "",
-			rendered: &renderedDefault,
-		}
-		msgmap[*id] = m
-	}
-	idx := msg.Utf16Index
-	body := msg.Body
-	m.text = insertAtUTF16Index(m.text, idx, body)
-
-	m.renderMessage(width)
-	if atr {
-		*renders = append(*renders, m.rendered)
-	}
-	return nil
-}
-
-func insertAtUTF16Index(base string, index uint32, insert string) string {
-	runes := []rune(base)
-	baseUTF16Units := utf16.Encode(runes)
-	if uint32(len(baseUTF16Units)) < index {
-		spacesNeeded := index - uint32(len(baseUTF16Units))
-		padding := strings.Repeat(" ", int(spacesNeeded))
-		base = base + padding
-
-		runes = []rune(base)
-		baseUTF16Units = utf16.Encode(runes)
-	}
-
-	insertRunes := []rune(insert)
-	insertUTF16Units := utf16.Encode(insertRunes)
-	result := make([]uint16, 0, len(baseUTF16Units)+len(insertUTF16Units))
-	result = append(result, baseUTF16Units[:index]...)
-	result = append(result, insertUTF16Units...)
-	result = append(result, baseUTF16Units[index:]...)
-	resultRunes := utf16.Decode(result)
-	return string(resultRunes)
-}
-
-func pubMessage(msg *lrcpb.Pub, msgmap map[uint32]*Message, width int) error {
-	if msg == nil {
-		return errors.New("no pub")
-	}
-	id := msg.Id
-	if id == nil {
-		return errors.New("no pub id")
-	}
-	m := msgmap[*id]
-	if m != nil {
-		m.active = false
-		m.renderMessage(width)
-	}
-	return nil
-}
-
-func initMessage(msg *lrcpb.Init, msgmap map[uint32]*Message, renders *[]*string, width int) error {
-	if msg == nil {
-		return errors.New("beeped tf up")
-	}
-	id := msg.Id
-	if id == nil {
-		return errors.New("beeped up")
-	}
-	renderedDefault := ""
-	m := &Message{
-		nick:     msg.Nick,
-		handle:   msg.ExternalID,
-		color:    msg.Color,
-		active:   true,
-		text:     "",
-		rendered: &renderedDefault,
-	}
-	m.renderMessage(width)
-	msgmap[*id] = m
-	*renders = append(*renders, m.rendered)
-	return nil
-}
-
-func (m *Message) renderMessage(width int) {
-	if m == nil {
-		return
+func (m *Message) renderMessage(gsd *globalsettingsdata) string {
+	if m == nil || m.collapsed() {
+		return ""
 	}
-	stylem := lipgloss.NewStyle().Width(width).Align(lipgloss.Left)
+	stylem := lipgloss.NewStyle().Width(gsd.width).Align(lipgloss.Left)
 	styleh := stylem.Foreground(ColorFromInt(m.color))
 	if m.active {
 		styleh = styleh.Reverse(true)
 		stylem = styleh
 	}
-	header := styleh.Render(renderName(m.nick, m.handle))
+	var name string
+	switch {
+	case m.impersonating():
+		styleh = WarningStyle.Width(gsd.width).Align(lipgloss.Left)
+		name = fmt.Sprintf("%s %s claims @%s but is signed as @%s", warning, renderName(m.nick, nil), *m.handle, m.signet.AuthorHandle)
+	case m.signet != nil:
+		name = renderName(m.nick, &m.signet.AuthorHandle)
+	case gsd.hideunverified:
+		name = fmt.Sprintf("%s %s", renderName(m.nick, nil), unverified)
+	default:
+		name = fmt.Sprintf("%s %s", renderName(m.nick, m.handle), unverified)
+	}
+	if ob := m.outbox.snapshot(); ob != nil && ob.State != OutboxPublished {
+		name = fmt.Sprintf("%s (%s)", name, ob.State)
+	} else if m.uri != nil {
+		name = fmt.Sprintf("%s %s", name, persisted)
+	} else if m.unsent {
+		name = fmt.Sprintf("%s (unsent)", name)
+	}
+	if m.selected {
+		name = fmt.Sprintf("%s %s", selection, name)
+	}
+	header := styleh.Render(name)
 	body := stylem.Render(m.text)
-	*m.rendered = fmt.Sprintf("%s\n%s\n", header, body)
+	return fmt.Sprintf("%s\n%s\n", header, body)
+}
+
+// collapsed reports whether m was pub'd with nothing in it, like a retracted
+// message, so that it takes up no room in the transcript
+func (m *Message) collapsed() bool {
+	return !m.active && m.text == ""
+}
+
+// impersonating reports whether the handle claimed over lrc disagrees with the
+// handle that the appview signed for this message
+func (m *Message) impersonating() bool {
+	return m.signet != nil && m.handle != nil && !strings.EqualFold(*m.handle, m.signet.AuthorHandle)
+}
+
+// newChannelModel sets up everything about a channel that doesn't depend on
+// how we are connected to it
+func newChannelModel(gsd *globalsettingsdata, wsurl string) channelmodel {
+	cm := channelmodel{}
+	cm.wsurl = wsurl
+	cm.gsd = gsd
+	cm.store = NewMessageStore(wsurl, func(msg *Message) string { return msg.renderMessage(gsd) })
+	cm.store.SetScrollback(gsd.scrollback)
+	cm.signets = make(map[uint32]*lex.SignetView)
+	cm.joined = make(map[string]bool)
+	cm.awaiting = make(map[uint32]string)
+	cm.vp = newTranscript(cm.store, gsd.width, gsd.height-2)
+	draft := textinput.New()
+	draft.Prompt = renderName(gsd.nick, gsd.handle) + " "
+	draft.PromptStyle = lipgloss.NewStyle().Foreground(ColorFromInt(gsd.color))
+	draft.Placeholder = "press i to start typing"
+	draft.Width = gsd.width - len(draft.Prompt) - 1
+	cm.draft = draft
+	return cm
 }
 
 func (m model) updateConnectingToChannel(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case connMsg:
 		m.gsd.state = Connected
-		cm := channelmodel{}
-		cm.wsurl = msg.wsurl
-		cm.gsd = m.gsd
+		cm := newChannelModel(m.gsd, msg.wsurl)
+		if m.clm != nil {
+			cm.channel = m.clm.curchannel()
+		}
 		cm.cancel = msg.cancel
-		cm.msgs = make(map[uint32]*Message)
-		vp := viewport.New(m.gsd.width, m.gsd.height-2)
-		cm.vp = vp
-		draft := textinput.New()
-		draft.Prompt = renderName(m.gsd.nick, m.gsd.handle) + " "
-		draft.PromptStyle = lipgloss.NewStyle().Foreground(ColorFromInt(m.gsd.color))
-		draft.Placeholder = "press i to start typing"
-		draft.Width = m.gsd.width - len(draft.Prompt) - 1
-		cm.draft = draft
-		go startLRCHandlers(msg.conn, m.gsd.nick, m.gsd.handle, m.gsd.color)
-		cm.lrcconn = msg.conn
+		cm.lrc = msg.lrc
 		cm.lexconn = msg.lexconn
-		cm.datachan = make(chan []byte)
-		go listenToLexConn(msg.lexconn)
-		go LRCWriter(cm.lrcconn, cm.datachan)
+		go listenToClient(msg.lrc, m.gsd.send)
+		go listenToLexConn(msg.lexconn, m.gsd.send)
+		m.cm.close()
 		m.cm = &cm
+		cm.hook(&hookEvent{Event: hookConnect})
 		m.clm = nil
 		return m, nil
 	}
@@ -968,39 +1065,18 @@ func (m model) updateDialingChannel(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case connSimpleMsg:
 		m.gsd.state = Connected
-		cm := channelmodel{}
-		cm.gsd = m.gsd
+		cm := newChannelModel(m.gsd, msg.wsurl)
 		cm.cancel = msg.cancel
-		cm.msgs = make(map[uint32]*Message)
-		vp := viewport.New(m.gsd.width, m.gsd.height-2)
-		cm.vp = vp
-		draft := textinput.New()
-		draft.Prompt = renderName(m.gsd.nick, m.gsd.handle) + " "
-		draft.PromptStyle = lipgloss.NewStyle().Foreground(ColorFromInt(m.gsd.color))
-		draft.Placeholder = "press i to start typing"
-		draft.Width = m.gsd.width - len(draft.Prompt) - 1
-		cm.draft = draft
-		go startLRCHandlers(msg.conn, m.gsd.nick, m.gsd.handle, m.gsd.color)
-		cm.lrcconn = msg.conn
-		cm.datachan = make(chan []byte)
-		cm.wsurl = msg.wsurl
+		cm.lrc = msg.lrc
+		go listenToClient(msg.lrc, m.gsd.send)
+		m.cm.close()
 		m.cm = &cm
+		cm.hook(&hookEvent{Event: hookConnect})
 		m.clm = nil
-		go LRCWriter(cm.lrcconn, cm.datachan)
 	}
 	return m, nil
 }
 
-func LRCWriter(conn *websocket.Conn, datachan chan []byte) {
-	for data := range datachan {
-		err := conn.WriteMessage(websocket.BinaryMessage, data)
-		if err != nil {
-			send(errMsg{err})
-			return
-		}
-	}
-}
-
 func renderName(nick *string, handle *string) string {
 	var n string
 	if nick != nil {
@@ -1013,99 +1089,67 @@ func renderName(nick *string, handle *string) string {
 	return fmt.Sprintf("%s%s", n, h)
 }
 
-func sendSet(datachan chan []byte, nick *string, handle *string, color *uint32) error {
-	evt := &lrcpb.Event{Msg: &lrcpb.Event_Set{Set: &lrcpb.Set{Nick: nick, ExternalID: handle, Color: color}}}
-	data, err := proto.Marshal(evt)
-	if err != nil {
-		return err
-	}
-	datachan <- data
-	return nil
-
-}
-
-func startLRCHandlers(conn *websocket.Conn, nick *string, handle *string, color *uint32) {
-	if conn == nil {
-		send(errMsg{errors.New("provided nil conn")})
-		return
-	}
-	evt := &lrcpb.Event{Msg: &lrcpb.Event_Set{Set: &lrcpb.Set{Nick: nick, ExternalID: handle, Color: color}}}
-	data, err := proto.Marshal(evt)
-	if err != nil {
-		send(errMsg{errors.New("failed to marshal: " + err.Error())})
-		return
-	}
-	conn.WriteMessage(websocket.BinaryMessage, data)
-
-	bep := "bep"
-	evt = &lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: &bep}}}
-	data, err = proto.Marshal(evt)
-	if err != nil {
-		send(errMsg{errors.New("failed to marshal: " + err.Error())})
-		return
-	}
-	conn.WriteMessage(websocket.BinaryMessage, data)
-	go listenToConn(conn)
-}
-
-type typedJSON struct {
-	Type string `json:"$type"`
+func listenToLexConn(conn *websocket.Conn, send func(tea.Msg)) {
+	err := readLexStream(conn, func(lsm *lex.SubscribeLexStream_Message) {
+		switch {
+		case lsm.SignetView != nil:
+			send(svMsg{lsm.SignetView})
+		case lsm.MessageView != nil:
+			send(mvMsg{lsm.MessageView})
+		}
+	})
+	send(errMsg{err})
 }
 
-func listenToLexConn(conn *websocket.Conn) {
+// readLexStream calls fn with every message on a channel's lex stream until
+// it ends, and returns why it did
+func readLexStream(conn *websocket.Conn, fn func(*lex.SubscribeLexStream_Message)) error {
 	for {
-		var rawMsg json.RawMessage
-		err := conn.ReadJSON(&rawMsg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			send(errMsg{err})
-			return
+			return err
 		}
-		var typed typedJSON
-		err = json.Unmarshal(rawMsg, &typed)
+		recorder.Lex(record.In, data)
+		var lsm lex.SubscribeLexStream_Message
+		err = json.Unmarshal(data, &lsm)
 		if err != nil {
-			send(errMsg{err})
-			return
-		}
-		switch typed.Type {
-		case "org.xcvr.lrc.defs#signetView":
-			var sv SignetView
-			err = json.Unmarshal(rawMsg, &sv)
-			if err != nil {
-				send(errMsg{err})
-				return
-			}
-			send(svMsg{&sv})
+			return err
 		}
+		fn(&lsm)
 	}
 }
 
 type svMsg struct {
-	signetView *SignetView
+	signetView *lex.SignetView
 }
 
-type SignetView struct {
-	Type         string    `json:"$type,const=org.xcvr.lrc.defs#signetView"`
-	URI          string    `json:"uri"`
-	IssuerHandle string    `json:"issuerHandle"`
-	ChannelURI   string    `json:"channelURI"`
-	LrcId        uint32    `json:"lrcID"`
-	AuthorHandle string    `json:"authorHandle"`
-	StartedAt    time.Time `json:"startedAt"`
+type mvMsg struct {
+	messageView *lex.MessageView
 }
 
-func listenToConn(conn *websocket.Conn) {
-	for {
-		_, data, err := conn.ReadMessage()
-		if err != nil {
-			send(errMsg{err})
-		}
-		var e lrcpb.Event
-		err = proto.Unmarshal(data, &e)
-		send(lrcEvent{&e})
+// listenToClient passes everything that happens in the channel on to the
+// program until the client hangs up, and then why unless we hung up
+func listenToClient(c *lrcclient.Client, send func(tea.Msg)) {
+	for ev := range c.Events() {
+		send(lrcEvent{ev, c})
+	}
+	if err := c.Err(); !errors.Is(err, lrcclient.ErrClosed) {
+		send(disconnectedMsg{c, err})
 	}
 }
 
-type lrcEvent struct{ e *lrcpb.Event }
+// disconnectedMsg is a client that was cut off from its channel
+type disconnectedMsg struct {
+	from *lrcclient.Client
+	err  error
+}
+
+// lrcEvent is an event from the channel, from says which client it came
+// through so that stragglers from a channel we have left can be told apart
+type lrcEvent struct {
+	ev   lrcclient.Event
+	from *lrcclient.Client
+}
 type connlistenerexitMsg struct{}
 type connwriterexitMsg struct{}
 
@@ -1127,57 +1171,68 @@ func (m model) updateResolvingChannel(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) dialingChannel(url string) tea.Cmd {
 	return func() tea.Msg {
-		dialer := websocket.DefaultDialer
-		dialer.Subprotocols = []string{"lrc.v1"}
 		ctx, cancel := context.WithCancel(context.Background())
-		conn, _, err := dialer.DialContext(ctx, fmt.Sprintf("wss://%s", url), http.Header{})
+		c, err := lrcclient.Connect(ctx, url, m.lrcConfig())
 		if err != nil {
 			cancel()
 			return errMsg{err}
 		}
-		return connSimpleMsg{conn, cancel, url}
+		return connSimpleMsg{c, cancel, url}
+	}
+}
+
+// lrcConfig is who we are in the channels we join
+func (m model) lrcConfig() lrcclient.Config {
+	return lrcclient.Config{
+		Nick:       m.gsd.nick,
+		ExternalID: m.gsd.handle,
+		Color:      m.gsd.color,
+		Scrollback: m.gsd.scrollback,
+		EditWindow: m.gsd.editwindow,
+		Tap:        recorder.LRC,
 	}
 }
 
 type connSimpleMsg struct {
-	conn   *websocket.Conn
+	lrc    *lrcclient.Client
 	cancel func()
 	wsurl  string
 }
 
 func (m model) connectToChannel(ctx context.Context, cancel func(), wsurl string) tea.Cmd {
 	return func() tea.Msg {
-		dialer := websocket.DefaultDialer
-		dialer.Subprotocols = []string{"lrc.v1"}
-		conn, _, err := dialer.DialContext(ctx, fmt.Sprintf("wss://%s", wsurl), http.Header{})
+		c, err := lrcclient.Connect(ctx, wsurl, m.lrcConfig())
 		if err != nil {
 			return errMsg{err}
 		}
 
-		dialer = websocket.DefaultDialer
-		c := m.clm.curchannel()
 		var uri string
-		if c != nil {
-			uri = c.URI
+		if channel := m.clm.curchannel(); channel != nil {
+			uri = channel.URI
 		}
-		lexconn, _, err := dialer.DialContext(ctx, fmt.Sprintf("wss://xcvr.org/xrpc/org.xcvr.lrc.subscribeLexStream?uri=%s", uri), http.Header{})
+		lexconn, err := m.gsd.xcvr.SubscribeLexStream(ctx, uri)
 		if err != nil {
+			c.Close()
 			return errMsg{err}
 		}
-		return connMsg{conn, lexconn, cancel, wsurl}
+		return connMsg{c, lexconn, cancel, wsurl}
 	}
 }
 
 type connMsg struct {
-	conn    *websocket.Conn
+	lrc     *lrcclient.Client
 	lexconn *websocket.Conn
 	cancel  func()
 	wsurl   string
 }
 
 const (
-	bullet   = "•"
-	ellipsis = "…"
+	bullet     = "•"
+	ellipsis   = "…"
+	persisted  = "✓"
+	unverified = "(unverified)"
+	selection  = "▶"
+	warning    = "⚠"
 )
 
 func (m model) updateGettingChannels(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -1200,10 +1255,10 @@ func (m model) updateGettingChannels(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (clm channellistmodel) curchannel() *Channel {
+func (clm channellistmodel) curchannel() *lex.ChannelView {
 	switch i := clm.list.SelectedItem().(type) {
 	case ChannelItem:
-		return &i.channel
+		return i.channel
 	}
 	return nil
 }
@@ -1225,7 +1280,7 @@ func (clm channellistmodel) updateChannelList(msg tea.Msg) (channellistmodel, te
 				if err != nil {
 					return clm, nil, err
 				}
-				return clm, ResolveChannel(cc.Host, did, rkey), nil
+				return clm, ResolveChannel(clm.gsd.xcvr.WithHost(fmt.Sprintf("http://%s", cc.Host)), did, rkey), nil
 			} else {
 				err := errors.New("bad list type")
 				return clm, nil, err
@@ -1237,34 +1292,18 @@ func (clm channellistmodel) updateChannelList(msg tea.Msg) (channellistmodel, te
 	return clm, cmd, nil
 }
 
-func ResolveChannel(host string, did string, rkey string) tea.Cmd {
+func ResolveChannel(c *xcvr.Client, did string, rkey string) tea.Cmd {
 	return func() tea.Msg {
-		c := &http.Client{Timeout: 10 * time.Second}
-		res, err := c.Get(fmt.Sprintf("http://%s/xrpc/org.xcvr.actor.resolveChannel?did=%s&rkey=%s", host, did, rkey))
-
-		if err != nil {
-			return errMsg{err}
-		}
-		if res.StatusCode != 200 {
-			return errMsg{errors.New(fmt.Sprintf("error resolving channel: %d", res.StatusCode))}
-		}
-		decoder := json.NewDecoder(res.Body)
-		var resolution Resolution
-		err = decoder.Decode(&resolution)
+		resolution, err := c.ResolveChannel(context.Background(), did, rkey)
 		if err != nil {
-			return errMsg{err}
+			return errMsg{errors.New("error resolving channel: " + err.Error())}
 		}
 		return resolutionMsg{resolution}
 	}
 }
 
 type resolutionMsg struct {
-	resolution Resolution
-}
-
-type Resolution struct {
-	URL string  `json:"url"`
-	URI *string `json:"uri,omitempty"`
+	resolution *lex.ResolveChannel_Output
 }
 
 func (m model) View() string {
@@ -1272,6 +1311,9 @@ func (m model) View() string {
 	if m.cmding {
 		pv = m.prompt.View()
 	}
+	if m.cmdout != nil {
+		return fmt.Sprintf("%s\n\n%s", *m.cmdout, subduedStyle.Render("press any key to continue"))
+	}
 	switch m.gsd.state {
 	case Splash:
 		return m.splashView()
@@ -1292,6 +1334,10 @@ func (m model) View() string {
 		return m.connectingView()
 	case Connected:
 		return m.cm.connectedView(m.cmding, pv)
+	case Searching:
+		return "searching..."
+	case SearchResults, History:
+		return m.sm.searchView(m.cmding, pv)
 	default:
 		return "under construction"
 	}
@@ -1303,8 +1349,11 @@ func (cm channelmodel) connectedView(cmding bool, prompt string) string {
 	if cmding {
 		footer = prompt
 	} else {
-		address := "lrc://"
-		address = fmt.Sprintf("%s%s", address, cm.wsurl)
+		drafting := "live"
+		if cm.privately() {
+			drafting = "private"
+		}
+		address := fmt.Sprintf("%s lrc://%s", drafting, cm.wsurl)
 		var topic string
 		if cm.topic != nil {
 			topic = *cm.topic
@@ -1376,13 +1425,49 @@ func (m model) splashView() string {
 	return offset.Render(s)
 }
 
-var send func(msg tea.Msg)
+// recorder captures the traffic of the session when we were started with
+// --record, it is nil otherwise
+var recorder *record.Writer
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			os.Exit(replayMain(os.Args[2:]))
+		case "serve":
+			os.Exit(serveMain(os.Args[2:]))
+		case "pipe":
+			os.Exit(pipeMain(os.Args[2:]))
+		case "bot":
+			os.Exit(botMain(os.Args[2:]))
+		case "ircd":
+			os.Exit(ircdMain(os.Args[2:]))
+		case "ssh-serve":
+			os.Exit(sshServeMain(os.Args[2:]))
+		}
+	}
+	recordpath := flag.String("record", "", "write every lrc and lex stream message to `file`")
+	flag.Parse()
+	if *recordpath != "" {
+		var err error
+		recorder, err = record.Create(*recordpath)
+		if err != nil {
+			fmt.Printf("couldn't start recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	fmt.Println("if you can see me before program quits i think that you should find a better terminal,")
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	send = p.Send
-	if _, err := p.Run(); err != nil {
+	var p *tea.Program
+	p = tea.NewProgram(initialModel(func(msg tea.Msg) { p.Send(msg) }), tea.WithAltScreen())
+	fm, err := p.Run()
+	if fm, ok := fm.(model); ok {
+		fm.cm.close()
+		fm.gsd.hooks.wait(hookExitWait)
+	}
+	if rerr := recorder.Close(); rerr != nil {
+		fmt.Printf("recording to %s failed: %v\n", *recordpath, rerr)
+	}
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
@@ -1484,119 +1569,14 @@ func defaultStyles() (s list.Styles) {
 	return s
 }
 
-const maxInt = int(^uint(0) >> 1)
-
-func JoinDeref(elems []*string, sep string) string {
-	switch len(elems) {
-	case 0:
-		return ""
-	case 1:
-		return *elems[0]
-	}
-
-	var n int
-	if len(sep) > 0 {
-		if len(sep) >= maxInt/(len(elems)-1) {
-			panic("strings: Join output length overflow")
-		}
-		n += len(sep) * (len(elems) - 1)
-	}
-	for _, elem := range elems {
-		if len(*elem) > maxInt-n {
-			panic("strings: Join output length overflow")
-		}
-		n += len(*elem)
-	}
-
-	var b strings.Builder
-	b.Grow(n)
-	b.WriteString(*elems[0])
-	for _, s := range elems[1:] {
-		b.WriteString(sep)
-		b.WriteString(*s)
-	}
-	return b.String()
-}
-
-type PasswordClient struct {
-	xrpc       *client.APIClient
-	accessjwt  *string
-	refreshjwt *string
-	did        *string
-}
-
-func NewPasswordClient(did string, host string) *PasswordClient {
-	return &PasswordClient{
-		xrpc: client.NewAPIClient(host),
-		did:  &did,
-	}
-}
-
-func (c *PasswordClient) CreateSession(ctx context.Context, identity string, secret string) error {
-	input := atproto.ServerCreateSession_Input{
-		Identifier: identity,
-		Password:   secret,
-	}
-	var out atproto.ServerCreateSession_Output
-	err := c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.server.createSession", nil, input, &out)
-	if err != nil {
-		return errors.New("I couldn't create a session: " + err.Error())
-	}
-	c.accessjwt = &out.AccessJwt
-	c.refreshjwt = &out.RefreshJwt
-	return nil
-}
-
-func (c *PasswordClient) RefreshSession(ctx context.Context) error {
-	c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.refreshjwt))
-	var out atproto.ServerRefreshSession_Output
-	err := c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.server.refreshSession", nil, nil, &out)
-	if err != nil {
-		return errors.New("failed to refresh session! " + err.Error())
-	}
-	c.accessjwt = &out.AccessJwt
-	c.refreshjwt = &out.RefreshJwt
-	return nil
-}
-
-func (c *PasswordClient) CreateXCVRMessage(message *lex.MessageRecord, ctx context.Context) (cid string, uri string, err error) {
-	input := atproto.RepoCreateRecord_Input{
-		Collection: "org.xcvr.lrc.message",
-		Repo:       *c.did,
-		Record:     &util.LexiconTypeDecoder{Val: message},
-	}
-	return c.createMyRecord(input, ctx)
-}
-
-func (c *PasswordClient) createMyRecord(input atproto.RepoCreateRecord_Input, ctx context.Context) (cid string, uri string, err error) {
-	if c.accessjwt == nil {
-		err = errors.New("must create a session first")
-		return
-	}
-	c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
-	var out atproto.RepoCreateRecord_Output
-	err = c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.createRecord", nil, input, &out)
-	if err != nil {
-		err1 := err.Error()
-		err = c.RefreshSession(ctx)
-		if err != nil {
-			err = errors.New(fmt.Sprintf("failed to refresh session while creating %s! first %s then %s", input.Collection, err1, err.Error()))
-			return
-		}
-		c.xrpc.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", *c.accessjwt))
-		out = atproto.RepoCreateRecord_Output{}
-		err = c.xrpc.LexDo(ctx, "POST", "application/json", "com.atproto.repo.createRecord", nil, input, &out)
-		if err != nil {
-			err = errors.New(fmt.Sprintf("not good, failed to create %s after failing then refreshing session! first %s then %s", input.Collection, err1, err.Error()))
-			return
-		}
-		cid = out.Cid
-		uri = out.Uri
-		return
+// color32 narrows a lexicon color, which is always in 0..0xffffff, to the
+// uint32 that lrc uses
+func color32(c *uint64) *uint32 {
+	if c == nil {
+		return nil
 	}
-	cid = out.Cid
-	uri = out.Uri
-	return
+	c32 := uint32(*c)
+	return &c32
 }
 
 func ColorFromInt(c *uint32) lipgloss.Color {
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+type outboxstate int
+
+const (
+	OutboxPending outboxstate = iota
+	OutboxFailed
+	OutboxPublished
+)
+
+func (s outboxstate) String() string {
+	switch s {
+	case OutboxPending:
+		return "pending"
+	case OutboxFailed:
+		return "failed"
+	case OutboxPublished:
+		return "published"
+	}
+	return "unknown"
+}
+
+const maxOutboxAttempts = 8
+const maxOutboxBackoff = 5 * time.Minute
+
+// OutboxEntry is a message record that we want in our repo but that hasn't
+// made it there yet. entries are kept on disk until they are published or
+// discarded so that a flaky pds doesn't eat anyone's messages
+type OutboxEntry struct {
+	ID       int               `json:"id"`
+	Did      string            `json:"did"`
+	Channel  string            `json:"channel"`
+	LrcID    *uint32           `json:"lrcID,omitempty"`
+	Record   lex.MessageRecord `json:"record"`
+	State    outboxstate       `json:"state"`
+	Attempts int               `json:"attempts"`
+	LastErr  *string           `json:"lastErr,omitempty"`
+	NextTry  time.Time         `json:"nextTry"`
+	URI      *string           `json:"uri,omitempty"`
+	CID      *string           `json:"cid,omitempty"`
+
+	// inflight is set while a publish of the entry is running, gen counts
+	// the publishes started so that a retry scheduled before the latest one
+	// knows it is stale
+	inflight bool
+	gen      int
+	// o is the outbox the entry is in, whose lock guards it
+	o *Outbox
+}
+
+// Outbox is shared by every ssh session of the same user, so mu guards the
+// entries along with everything about them that changes. outside of the
+// outbox an entry is only read through snapshot
+type Outbox struct {
+	path    string
+	mu      sync.Mutex
+	nextid  int
+	entries []*OutboxEntry
+}
+
+func dataDir() (string, error) {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "ttyxcvr"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "ttyxcvr"), nil
+}
+
+func loadOutbox() (*Outbox, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return &Outbox{}, err
+	}
+	return loadOutboxIn(dir)
+}
+
+// loadOutboxIn loads the outbox kept in dir
+func loadOutboxIn(dir string) (*Outbox, error) {
+	o := &Outbox{path: filepath.Join(dir, "outbox.json")}
+	data, err := os.ReadFile(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+	err = json.Unmarshal(data, &o.entries)
+	if err != nil {
+		return o, errors.New("outbox is corrupt: " + err.Error())
+	}
+	for _, e := range o.entries {
+		e.o = o
+		if e.ID >= o.nextid {
+			o.nextid = e.ID + 1
+		}
+	}
+	return o, nil
+}
+
+// snapshot copies e as it is right now
+func (e *OutboxEntry) snapshot() *OutboxEntry {
+	if e == nil {
+		return nil
+	}
+	e.o.mu.Lock()
+	defer e.o.mu.Unlock()
+	s := *e
+	return &s
+}
+
+// save writes every unpublished entry to disk, published entries only live
+// for the rest of the session so that their state can still be rendered.
+// o.mu must be held
+func (o *Outbox) save() error {
+	if o.path == "" {
+		return nil
+	}
+	unpublished := make([]*OutboxEntry, 0, len(o.entries))
+	for _, e := range o.entries {
+		if e.State != OutboxPublished {
+			unpublished = append(unpublished, e)
+		}
+	}
+	data, err := json.MarshalIndent(unpublished, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(o.path), 0o700)
+	if err != nil {
+		return err
+	}
+	tmp := o.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+func (o *Outbox) add(did string, channel string, lrcid *uint32, lmr lex.MessageRecord) (*OutboxEntry, error) {
+	e := &OutboxEntry{
+		Did:     did,
+		Channel: channel,
+		LrcID:   lrcid,
+		Record:  lmr,
+		State:   OutboxPending,
+		NextTry: time.Now(),
+		o:       o,
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e.ID = o.nextid
+	o.nextid++
+	o.entries = append(o.entries, e)
+	return e, o.save()
+}
+
+func (o *Outbox) get(id int) *OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.find(id)
+}
+
+// find is get with o.mu held
+func (o *Outbox) find(id int) *OutboxEntry {
+	for _, e := range o.entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) discard(id int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, e := range o.entries {
+		if e.ID == id {
+			// the publish would still land, and with nothing left here to
+			// show for it
+			if e.inflight {
+				return fmt.Errorf("outbox entry %d is being published", id)
+			}
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return o.save()
+		}
+	}
+	return fmt.Errorf("no outbox entry %d", id)
+}
+
+func (o *Outbox) published(id int, uri string, cid string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e := o.find(id)
+	if e == nil {
+		return nil
+	}
+	e.inflight = false
+	e.State = OutboxPublished
+	e.URI = &uri
+	e.CID = &cid
+	e.LastErr = nil
+	return o.save()
+}
+
+// failed records a failed attempt and returns how long to wait before the
+// next one, or false if we have given up on the entry
+func (o *Outbox) failed(id int, err error) (time.Duration, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e := o.find(id)
+	if e == nil {
+		return 0, false, nil
+	}
+	e.inflight = false
+	e.Attempts++
+	errs := err.Error()
+	e.LastErr = &errs
+	var verr *lex.ValidationError
+	if e.Attempts >= maxOutboxAttempts || errors.As(err, &verr) {
+		e.State = OutboxFailed
+		return 0, false, o.save()
+	}
+	backoff := time.Second << e.Attempts
+	if backoff > maxOutboxBackoff {
+		backoff = maxOutboxBackoff
+	}
+	e.NextTry = time.Now().Add(backoff)
+	return backoff, true, o.save()
+}
+
+func (o *Outbox) retry(id int) (*OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e := o.find(id)
+	if e == nil {
+		return nil, fmt.Errorf("no outbox entry %d", id)
+	}
+	if e.State == OutboxPublished {
+		return nil, fmt.Errorf("outbox entry %d is already published", id)
+	}
+	if e.inflight {
+		return nil, fmt.Errorf("outbox entry %d is being published", id)
+	}
+	e.State = OutboxPending
+	e.Attempts = 0
+	e.NextTry = time.Now()
+	return e, o.save()
+}
+
+// pending returns the entries that belong to did and are still waiting to be
+// published, leaving out the ones that are being published right now
+func (o *Outbox) pending(did string) []*OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := make([]*OutboxEntry, 0)
+	for _, e := range o.entries {
+		if e.State == OutboxPending && !e.inflight && e.Did == did {
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+// start marks e as being published and returns its record, unless another
+// session got to it first
+func (o *Outbox) start(e *OutboxEntry) (lex.MessageRecord, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if e.inflight || e.State != OutboxPending {
+		return lex.MessageRecord{}, false
+	}
+	e.inflight = true
+	e.gen++
+	return e.Record, true
+}
+
+func (o *Outbox) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.entries) == 0 {
+		return "outbox is empty"
+	}
+	var b strings.Builder
+	b.WriteString("outbox\n\n")
+	for _, e := range o.entries {
+		body := e.Record.Body
+		if runes := []rune(body); len(runes) > 40 {
+			body = string(runes[:40]) + ellipsis
+		}
+		fmt.Fprintf(&b, "%3d %-9s %s %q (%d attempts)\n", e.ID, e.State, e.Channel, body, e.Attempts)
+		if e.LastErr != nil && e.State != OutboxPublished {
+			fmt.Fprintf(&b, "    %s\n", subduedStyle.Render(*e.LastErr))
+		}
+	}
+	b.WriteString("\n:outbox retry <id> | :outbox discard <id>")
+	return b.String()
+}
+
+// outboxChanged rerenders the message that e was created from, if it is still
+// on screen
+func (cm *channelmodel) outboxChanged(entry *OutboxEntry) {
+	e := entry.snapshot()
+	if cm == nil || e == nil || e.LrcID == nil || cm.wsurl != e.Channel {
+		return
+	}
+	m := cm.store.Get(*e.LrcID)
+	if m == nil || m.outbox != entry {
+		return
+	}
+	if e.URI != nil {
+		m.uri = e.URI
+		m.cid = e.CID
+	}
+	cm.store.Touch(m.id)
+	cm.redraw()
+}
+
+// publishCmd publishes e, which is in flight until publishedMsg or
+// publishFailedMsg comes back for it
+func publishCmd(xrpc *lrcclient.PasswordClient, e *OutboxEntry) tea.Cmd {
+	lmr, ok := e.o.start(e)
+	if !ok {
+		return nil
+	}
+	id := e.ID
+	return func() tea.Msg {
+		cid, uri, err := xrpc.CreateXCVRMessage(&lmr, context.Background())
+		if err != nil {
+			return publishFailedMsg{id, err}
+		}
+		return publishedMsg{id, uri, cid}
+	}
+}
+
+func retryAfter(d time.Duration, e *OutboxEntry) tea.Cmd {
+	s := e.snapshot()
+	id, gen := s.ID, s.gen
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return retryMsg{id, gen}
+	})
+}
+
+// scheduleCmd publishes e now if it is due, or once it is
+func scheduleCmd(xrpc *lrcclient.PasswordClient, e *OutboxEntry) tea.Cmd {
+	if wait := time.Until(e.snapshot().NextTry); wait > 0 {
+		return retryAfter(wait, e)
+	}
+	return publishCmd(xrpc, e)
+}
+
+type publishedMsg struct {
+	id  int
+	uri string
+	cid string
+}
+
+type publishFailedMsg struct {
+	id  int
+	err error
+}
+
+type publishInvalidMsg struct {
+	err error
+}
+
+type retryMsg struct {
+	id  int
+	gen int
+}
+
+type outboxMsg struct {
+	value []string
+}
+
+func (m model) updateOutbox(args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		out := m.gsd.outbox.String()
+		m.cmdout = &out
+		return m, nil
+	}
+	if len(args) != 2 {
+		out := "usage: :outbox [retry|discard <id>]"
+		m.cmdout = &out
+		return m, nil
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		out := fmt.Sprintf("%s is not an outbox id", args[1])
+		m.cmdout = &out
+		return m, nil
+	}
+	switch args[0] {
+	case "retry", "r":
+		e, err := m.gsd.outbox.retry(id)
+		if err != nil {
+			out := err.Error()
+			m.cmdout = &out
+			return m, nil
+		}
+		m.cm.outboxChanged(e)
+		if m.gsd.xrpc == nil || m.gsd.xrpc.DID() != e.Did {
+			out := fmt.Sprintf("outbox entry %d will be retried once you :login as its author", id)
+			m.cmdout = &out
+			return m, nil
+		}
+		return m, publishCmd(m.gsd.xrpc, e)
+	case "discard", "d":
+		e := m.gsd.outbox.get(id)
+		err := m.gsd.outbox.discard(id)
+		if err != nil {
+			out := err.Error()
+			m.cmdout = &out
+			return m, nil
+		}
+		if e != nil && m.cm != nil && e.LrcID != nil && m.cm.wsurl == e.Channel {
+			if msg := m.cm.store.Get(*e.LrcID); msg != nil && msg.outbox == e {
+				msg.outbox = nil
+				m.cm.store.Touch(msg.id)
+				m.cm.redraw()
+			}
+		}
+		return m, nil
+	}
+	out := fmt.Sprintf("unknown outbox action %s", args[0])
+	m.cmdout = &out
+	return m, nil
+}
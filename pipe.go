@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gorilla/websocket"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/xcvr"
+)
+
+// pipemodel is a channel without a screen. lines from stdin are sent as whole
+// messages and messages are printed to stdout once they are pub'd, or with
+// --json every event is written out as it arrives
+type pipemodel struct {
+	lrc     *lrcclient.Client
+	signets map[uint32]*lex.SignetView
+	// pending counts the lines we sent that haven't come back pub'd, so that
+	// we don't hang up on them
+	pending int
+	closing bool
+	echo    bool
+	out     io.Writer
+	// enc is set when events are written as json instead of messages as
+	// lines
+	enc *json.Encoder
+}
+
+type lineMsg struct {
+	line string
+}
+
+type stdinClosedMsg struct {
+	err error
+}
+
+func pipeMain(args []string) int {
+	fs := flag.NewFlagSet("pipe", flag.ExitOnError)
+	join := fs.String("join", "", "channel to join, an at:// uri or an address like :dial takes")
+	nick := fs.String("nick", "wanderer", "nick to send messages as")
+	color := fs.Uint("color", 33096, "color to send messages as")
+	listen := fs.Bool("listen", false, "keep printing messages after stdin is closed")
+	echo := fs.Bool("echo", false, "print the messages we send as well")
+	jsonout := fs.Bool("json", false, "write every event as a line of json instead of printing messages")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr pipe --join channel [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *join == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	events := make(chan tea.Msg, 64)
+	send := func(msg tea.Msg) { events <- msg }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c32 := uint32(*color)
+	cfg := lrcclient.Config{
+		Nick:  nick,
+		Color: &c32,
+		// nothing is ever scrolled back to, so finished messages can go as
+		// soon as they have been printed
+		Scrollback: 1,
+	}
+	c, lexconn, err := joinChannel(ctx, xcvr.NewClient(xcvr.DefaultHost), *join, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't join %s: %v\n", *join, err)
+		return 1
+	}
+	defer c.Close()
+	go listenToClient(c, send)
+	if lexconn != nil {
+		defer lexconn.Close()
+		go listenToLexConn(lexconn, send)
+	}
+	go readLines(os.Stdin, send)
+
+	pm := newPipe(c, os.Stdout, *echo, *jsonout)
+	return pm.run(events, *listen, os.Stderr)
+}
+
+func newPipe(c *lrcclient.Client, out io.Writer, echo bool, jsonout bool) *pipemodel {
+	pm := &pipemodel{
+		lrc:     c,
+		signets: make(map[uint32]*lex.SignetView),
+		echo:    echo,
+		out:     out,
+	}
+	if jsonout {
+		pm.enc = json.NewEncoder(out)
+	}
+	return pm
+}
+
+// run handles events until stdin is closed and everything we sent has come
+// back, or until the channel goes away if listen is set, and returns the exit
+// code of pipe
+func (pm *pipemodel) run(events <-chan tea.Msg, listen bool, stderr io.Writer) int {
+	for msg := range events {
+		switch msg := msg.(type) {
+		case stdinClosedMsg:
+			if msg.err != nil {
+				fmt.Fprintf(stderr, "couldn't read stdin: %v\n", msg.err)
+			}
+			pm.closing = !listen
+		case disconnectedMsg:
+			if websocket.IsCloseError(msg.err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return 0
+			}
+			fmt.Fprintln(stderr, msg.err)
+			return 1
+		case errMsg:
+			fmt.Fprintln(stderr, msg.err)
+			return 1
+		default:
+			err := pm.update(msg)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+		}
+		if pm.closing && pm.pending == 0 {
+			return 0
+		}
+	}
+	return 0
+}
+
+// joinChannel connects to the channel with the given at:// uri, along with its
+// lex stream, or dials the lrc websocket at any other address
+func joinChannel(ctx context.Context, c *xcvr.Client, join string, cfg lrcclient.Config) (*lrcclient.Client, *websocket.Conn, error) {
+	url, err := resolveJoin(ctx, c, join)
+	if err != nil {
+		return nil, nil, err
+	}
+	lrc, err := lrcclient.Connect(ctx, url, cfg)
+	if err != nil || !strings.HasPrefix(join, "at://") {
+		return lrc, nil, err
+	}
+	lexconn, err := c.SubscribeLexStream(ctx, join)
+	if err != nil {
+		lrc.Close()
+		return nil, nil, err
+	}
+	return lrc, lexconn, nil
+}
+
+// resolveJoin returns the lrc websocket of the channel with the given at://
+// uri, any other address is already one
+func resolveJoin(ctx context.Context, c *xcvr.Client, join string) (string, error) {
+	if !strings.HasPrefix(join, "at://") {
+		return join, nil
+	}
+	channels, err := c.GetChannels(ctx)
+	if err != nil {
+		return "", errors.New("error getting channels: " + err.Error())
+	}
+	var channel *lex.ChannelView
+	for _, cv := range channels {
+		if cv.URI == join {
+			channel = cv
+			break
+		}
+	}
+	if channel == nil {
+		return "", errors.New("no channel at " + join)
+	}
+	did, err := DidFromUri(join)
+	if err != nil {
+		return "", err
+	}
+	rkey, err := RkeyFromUri(join)
+	if err != nil {
+		return "", err
+	}
+	resolution, err := c.ResolveChannel(ctx, did, rkey)
+	if err != nil {
+		return "", errors.New("error resolving channel: " + err.Error())
+	}
+	return channel.Host + resolution.URL, nil
+}
+
+func readLines(r io.Reader, send func(tea.Msg)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		send(lineMsg{scanner.Text()})
+	}
+	send(stdinClosedMsg{scanner.Err()})
+}
+
+func (pm *pipemodel) update(msg tea.Msg) error {
+	switch msg := msg.(type) {
+	case lineMsg:
+		if msg.line == "" {
+			return nil
+		}
+		err := pm.lrc.Send(msg.line)
+		if err != nil {
+			return err
+		}
+		pm.pending++
+	case svMsg:
+		sv := msg.signetView
+		lrcid := uint32(sv.LrcID)
+		pm.signets[lrcid] = sv
+		if pm.enc != nil {
+			se, err := lexStreamEvent("signetView", &lrcid, sv)
+			if err != nil {
+				return err
+			}
+			return pm.emit(se)
+		}
+	case mvMsg:
+		if pm.enc != nil {
+			se, err := lexStreamEvent("messageView", pm.signed(msg.messageView.SignetURI), msg.messageView)
+			if err != nil {
+				return err
+			}
+			return pm.emit(se)
+		}
+	case lrcEvent:
+		ev := msg.ev
+		var err error
+		if pm.enc != nil {
+			var se *streamEvent
+			se, err = lrcStreamEvent(ev.Raw)
+			if err != nil {
+				return err
+			}
+			if ev.Message != nil {
+				se.Mine = ev.Message.Mine
+				mj := pm.messageJSON(ev.Message)
+				se.Message = &mj
+			}
+			err = pm.emit(se)
+		}
+		if ev.Kind == lrcclient.KindPub && ev.Message != nil {
+			m := ev.Message
+			if m.Mine {
+				pm.pending--
+			}
+			if pm.enc == nil && m.Text != "" && (!m.Mine || pm.echo) {
+				fmt.Fprintln(pm.out, pm.line(m))
+			}
+			delete(pm.signets, m.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// emit writes se, filling in the message it is about if it doesn't have one
+// yet and we still have it
+func (pm *pipemodel) emit(se *streamEvent) error {
+	if se.ID != nil && se.Message == nil {
+		if m, ok := pm.lrc.Store().Get(*se.ID); ok {
+			se.Mine = m.Mine
+			mj := pm.messageJSON(&m)
+			se.Message = &mj
+		}
+	}
+	return pm.enc.Encode(se)
+}
+
+// signed returns the id of the message with the given signet, if we still
+// have it
+func (pm *pipemodel) signed(uri string) *uint32 {
+	for id, sv := range pm.signets {
+		if sv.URI == uri {
+			return &id
+		}
+	}
+	return nil
+}
+
+func (pm *pipemodel) messageJSON(m *lrcclient.Message) messageJSON {
+	return messageJSON{
+		ID:     m.ID,
+		Nick:   m.Nick,
+		Handle: m.ExternalID,
+		Color:  m.Color,
+		Active: m.Active,
+		Text:   m.Text,
+		Signet: pm.signets[m.ID],
+	}
+}
+
+// line is how a message is printed by pipe, nick@handle: text on a single
+// line, where the handle is the signed one if there is a signet
+func (pm *pipemodel) line(m *lrcclient.Message) string {
+	handle := m.ExternalID
+	if sv := pm.signets[m.ID]; sv != nil {
+		handle = &sv.AuthorHandle
+	}
+	name := renderName(m.Nick, handle)
+	if name == "" {
+		name = "anon"
+	}
+	return fmt.Sprintf("%s: %s", name, strings.ReplaceAll(m.Text, "\n", " "))
+}
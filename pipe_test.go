@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/relay"
+)
+
+// jsonLine is what the test cares about in a line of pipe --json
+type jsonLine struct {
+	Type    string         `json:"type"`
+	Time    time.Time      `json:"time"`
+	ID      *uint32        `json:"id"`
+	Mine    bool           `json:"mine"`
+	Event   map[string]any `json:"event"`
+	Message *messageJSON   `json:"message"`
+}
+
+// TestPipeJSON runs pipe --json against a relay while bob talks to it, and
+// checks the lines it writes for bob's message and then for its own
+func TestPipeJSON(t *testing.T) {
+	srv := httptest.NewServer(relay.New("the topic"))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	connect := func(nick string) *lrcclient.Client {
+		c, err := lrcclient.Connect(context.Background(), url, lrcclient.Config{Nick: &nick, Scrollback: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	c := connect("pip")
+	bob := connect("bob")
+	go func() {
+		for range bob.Events() {
+		}
+	}()
+
+	events := make(chan tea.Msg, 64)
+	send := func(msg tea.Msg) { events <- msg }
+	go listenToClient(c, send)
+	stdin, typed := io.Pipe()
+	go readLines(stdin, send)
+	stdout, out := io.Pipe()
+	var stderr strings.Builder
+	done := make(chan int)
+	go func() {
+		code := newPipe(c, out, false, true).run(events, false, &stderr)
+		out.Close()
+		done <- code
+	}()
+	dec := json.NewDecoder(stdout)
+
+	// lines until the pub of a message, leaving out the gets that come
+	// whenever someone joins
+	message := func() []jsonLine {
+		var lines []jsonLine
+		for {
+			var l jsonLine
+			err := dec.Decode(&l)
+			if err != nil {
+				t.Fatalf("after %+v: %v", lines, err)
+			}
+			if l.Type == "get" {
+				continue
+			}
+			lines = append(lines, l)
+			if l.Type == "pub" {
+				return lines
+			}
+		}
+	}
+	check := func(lines []jsonLine, nick string, text string, mine bool) {
+		t.Helper()
+		types := []string{"init", "insert", "pub"}
+		if len(lines) != len(types) {
+			t.Fatalf("got %+v, want %v", lines, types)
+		}
+		id := lines[0].ID
+		for i, l := range lines {
+			if l.Type != types[i] || l.Time.IsZero() || l.Mine != mine || l.Event == nil {
+				t.Errorf("line %d is %+v, want %s with mine %v", i, l, types[i], mine)
+			}
+			if id == nil || l.ID == nil || *l.ID != *id {
+				t.Errorf("line %d is about %v, want %v", i, l.ID, id)
+			}
+			m := l.Message
+			if m == nil || m.Nick == nil || *m.Nick != nick || m.ID != *l.ID {
+				t.Fatalf("line %d has message %+v, want %s's", i, m, nick)
+			}
+		}
+		if got := lines[0].Event["nick"]; got != nick {
+			t.Errorf("init has nick %v, want %s", got, nick)
+		}
+		if got := lines[1].Event["body"]; got != text {
+			t.Errorf("insert has body %v, want %s", got, text)
+		}
+		if m := lines[1].Message; m.Text != text || !m.Active {
+			t.Errorf("after the insert the message is %+v, want %s being typed", m, text)
+		}
+		if m := lines[2].Message; m.Text != text || m.Active {
+			t.Errorf("after the pub the message is %+v, want %s pub'd", m, text)
+		}
+	}
+
+	err := bob.Send("yo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(message(), "bob", "yo", false)
+	_, err = io.WriteString(typed, "hi\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(message(), "pip", "hi", true)
+
+	// pipe is done once stdin is closed and what it sent came back
+	go io.Copy(io.Discard, stdout)
+	typed.Close()
+	select {
+	case code := <-done:
+		if code != 0 || stderr.Len() != 0 {
+			t.Errorf("pipe exited %d with %q", code, stderr.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("pipe didn't exit")
+	}
+}
@@ -0,0 +1,322 @@
+// Package record reads and writes capture files of the traffic between a
+// client and an lrc channel, for debugging the protocol and for replaying
+// conversations without a network.
+//
+// A capture file starts with the six bytes "lrcrec" followed by the format
+// version as a protobuf varint, currently 1. After that it is a sequence of
+// frames, each of which is a varint length followed by that many bytes of a
+// protobuf message with this schema:
+//
+//	message Frame {
+//	  // nanoseconds since the recording started, from a monotonic clock
+//	  uint64 time = 1;
+//	  Direction direction = 2;
+//	  oneof payload {
+//	    // an lrc.v1 websocket message, an encoded lrcpb.Event
+//	    bytes lrc = 3;
+//	    // an org.xcvr.lrc.subscribeLexStream message, as the json it arrived as
+//	    bytes lex = 4;
+//	  }
+//	}
+//
+//	enum Direction {
+//	  IN = 0;
+//	  OUT = 1;
+//	}
+//
+// Unknown fields are skipped when reading so that later versions can add to a
+// frame without breaking older readers, anything that changes the meaning of
+// an existing field bumps the version.
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	Magic   = "lrcrec"
+	Version = 1
+)
+
+type Direction int
+
+const (
+	In Direction = iota
+	Out
+)
+
+func (d Direction) String() string {
+	switch d {
+	case In:
+		return "in"
+	case Out:
+		return "out"
+	}
+	return "unknown"
+}
+
+const (
+	fieldTime      protowire.Number = 1
+	fieldDirection protowire.Number = 2
+	fieldLRC       protowire.Number = 3
+	fieldLex       protowire.Number = 4
+)
+
+// maxFrameSize bounds how much a corrupt length prefix can make a Reader
+// allocate
+const maxFrameSize = 16 << 20
+
+// Frame is a single websocket message, exactly one of LRC and Lex is set
+type Frame struct {
+	Time      time.Duration
+	Direction Direction
+	LRC       []byte
+	Lex       []byte
+}
+
+// Writer appends frames to a capture. it is safe to use from several
+// goroutines and a nil *Writer discards everything, so callers don't need to
+// check whether they are recording. the first error stops the recording and
+// is kept for Err and Close to report
+type Writer struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	c     io.Closer
+	start time.Time
+	err   error
+}
+
+// Create starts a new capture at path, replacing anything that was there
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.c = f
+	return w, nil
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	header := protowire.AppendVarint([]byte(Magic), Version)
+	_, err := bw.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, start: time.Now()}, bw.Flush()
+}
+
+// LRC records an lrc websocket message going in the given direction
+func (w *Writer) LRC(d Direction, data []byte) {
+	w.write(d, fieldLRC, data)
+}
+
+// Lex records a lex stream message going in the given direction
+func (w *Writer) Lex(d Direction, data []byte) {
+	w.write(d, fieldLex, data)
+}
+
+func (w *Writer) write(d Direction, field protowire.Number, data []byte) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	var frame []byte
+	frame = protowire.AppendTag(frame, fieldTime, protowire.VarintType)
+	frame = protowire.AppendVarint(frame, uint64(time.Since(w.start)))
+	if d != In {
+		frame = protowire.AppendTag(frame, fieldDirection, protowire.VarintType)
+		frame = protowire.AppendVarint(frame, uint64(d))
+	}
+	frame = protowire.AppendTag(frame, field, protowire.BytesType)
+	frame = protowire.AppendBytes(frame, data)
+	_, err := w.w.Write(protowire.AppendVarint(nil, uint64(len(frame))))
+	if err == nil {
+		_, err = w.w.Write(frame)
+	}
+	if err == nil {
+		// flushing every frame means a crash still leaves a capture of
+		// everything up to it, which is usually the part we want
+		err = w.w.Flush()
+	}
+	w.err = err
+}
+
+// Err returns the error that stopped the recording, if there was one
+func (w *Writer) Err() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if w.c != nil {
+		err = errors.Join(err, w.c.Close())
+	}
+	w.err = errors.New("recording is closed")
+	return err
+}
+
+// Reader reads the frames of a capture in order
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader checks that r holds a capture in a version that we understand
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(Magic))
+	_, err := io.ReadFull(br, magic)
+	if err != nil || !bytes.Equal(magic, []byte(Magic)) {
+		return nil, errors.New("not an lrc capture")
+	}
+	version, err := readVarint(br)
+	if err != nil {
+		return nil, errors.New("not an lrc capture")
+	}
+	if version != Version {
+		return nil, fmt.Errorf("capture is version %d, only version %d is supported", version, Version)
+	}
+	return &Reader{br}, nil
+}
+
+// Next returns the next frame, or io.EOF once there aren't any more
+func (r *Reader) Next() (*Frame, error) {
+	size, err := readVarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes is too big", size)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(r.r, data)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return parseFrame(data)
+}
+
+// ReadAll reads every frame of the capture at path
+func ReadAll(path string) ([]*Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]*Frame, 0)
+	for {
+		frame, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func readVarint(r io.ByteReader) (uint64, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if len(buf) != 0 && errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		buf = append(buf, b)
+		if b < 0x80 {
+			break
+		}
+	}
+	v, n := protowire.ConsumeVarint(buf)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return v, nil
+}
+
+func parseFrame(data []byte) (*Frame, error) {
+	frame := &Frame{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == fieldTime && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			frame.Time = time.Duration(v)
+			data = data[n:]
+		case num == fieldDirection && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			frame.Direction = Direction(v)
+			data = data[n:]
+		case (num == fieldLRC || num == fieldLex) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			if num == fieldLRC {
+				frame.LRC = v
+			} else {
+				frame.Lex = v
+			}
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	if (frame.LRC == nil) == (frame.Lex == nil) {
+		return nil, errors.New("frame needs exactly one of lrc or lex")
+	}
+	return frame, nil
+}
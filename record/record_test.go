@@ -0,0 +1,176 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type frame struct {
+	d   Direction
+	lex bool
+	b   []byte
+}
+
+var frames = []frame{
+	{In, false, []byte{0x0a, 0x02, 0x08, 0x01}},
+	{Out, false, []byte("typed")},
+	{In, true, []byte(`{"$type":"org.xcvr.lrc.defs#signetView"}`)},
+	// long enough that its length takes two bytes
+	{Out, true, bytes.Repeat([]byte("x"), 300)},
+	{In, false, []byte{}},
+}
+
+// capture records frames and returns the bytes of the capture along with
+// where each frame ends in them
+func capture(t *testing.T) ([]byte, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ends := make([]int, 0, len(frames))
+	for _, f := range frames {
+		if f.lex {
+			w.Lex(f.d, f.b)
+		} else {
+			w.LRC(f.d, f.b)
+		}
+		ends = append(ends, buf.Len())
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), ends
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, _ := capture(t)
+	if header := append([]byte(Magic), Version); !bytes.HasPrefix(data, header) {
+		t.Fatalf("capture starts with %q, want %q", data[:len(header)], header)
+	}
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last Frame
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if got.Direction != want.d {
+			t.Errorf("frame %d went %s, want %s", i, got.Direction, want.d)
+		}
+		payload, other := got.LRC, got.Lex
+		if want.lex {
+			payload, other = got.Lex, got.LRC
+		}
+		if !bytes.Equal(payload, want.b) || other != nil {
+			t.Errorf("frame %d is lrc %q lex %q, want %q", i, got.LRC, got.Lex, want.b)
+		}
+		if got.Time < last.Time {
+			t.Errorf("frame %d at %v is before the one before it at %v", i, got.Time, last.Time)
+		}
+		last = *got
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("after the last frame got %v, want io.EOF", err)
+	}
+}
+
+// TestTruncated cuts a capture off at every byte after its header, which
+// has to read as the frames that made it whole and then, unless the cut was
+// between frames, io.ErrUnexpectedEOF
+func TestTruncated(t *testing.T) {
+	data, ends := capture(t)
+	start := len(Magic) + 1
+	for cut := start; cut < len(data); cut++ {
+		r, err := NewReader(bytes.NewReader(data[:cut]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for {
+			_, err = r.Next()
+			if err != nil {
+				break
+			}
+			n++
+		}
+		whole := 0
+		for whole < len(ends) && ends[whole] <= cut {
+			whole++
+		}
+		want := io.ErrUnexpectedEOF
+		if cut == start || whole > 0 && ends[whole-1] == cut {
+			want = io.EOF
+		}
+		if n != whole || !errors.Is(err, want) {
+			t.Errorf("cut at %d: read %d frames then %v, want %d then %v", cut, n, err, whole, want)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{Magic + "\x02", "capture is version 2, only version 1 is supported"},
+		{Magic + "\x80\x01", "capture is version 128, only version 1 is supported"},
+		{Magic, "not an lrc capture"},
+		{"lrcREC\x01", "not an lrc capture"},
+		{"", "not an lrc capture"},
+	}
+	for _, tt := range tests {
+		_, err := NewReader(strings.NewReader(tt.data))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("reading %q got %v, want %s", tt.data, err, tt.err)
+		}
+	}
+}
+
+// TestUnknownFields checks that a frame from a later version with a field we
+// don't know about still reads
+func TestUnknownFields(t *testing.T) {
+	var f []byte
+	f = protowire.AppendTag(f, fieldTime, protowire.VarintType)
+	f = protowire.AppendVarint(f, 5)
+	f = protowire.AppendTag(f, 9, protowire.BytesType)
+	f = protowire.AppendBytes(f, []byte("later"))
+	f = protowire.AppendTag(f, fieldLRC, protowire.BytesType)
+	f = protowire.AppendBytes(f, []byte("hi"))
+	data := protowire.AppendVarint([]byte(Magic), Version)
+	data = protowire.AppendVarint(data, uint64(len(f)))
+	data = append(data, f...)
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Time != 5 || string(got.LRC) != "hi" || got.Lex != nil {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestNilWriter(t *testing.T) {
+	var w *Writer
+	w.LRC(In, []byte("dropped"))
+	w.Lex(Out, []byte("dropped"))
+	if err := w.Err(); err != nil {
+		t.Error(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
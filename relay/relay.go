@@ -0,0 +1,320 @@
+// Package relay is a small lrc.v1 channel server. it hands out message ids,
+// relays everyone's typing to everyone else as it happens and answers Get with
+// a fixed topic, which is enough for a channel on a LAN or a counterpart for
+// testing clients against. there is no persistence and no moderation, Mute,
+// Kick, Ban and friends are accepted and ignored
+package relay
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// maxEventSize bounds a single event from a client
+	maxEventSize = 1 << 16
+	// sendBuffer is how many events a client can fall behind by before it
+	// gets dropped rather than holding everyone else up
+	sendBuffer = 256
+)
+
+// Server is an http.Handler that upgrades every request to an lrc.v1 websocket
+// and puts it in the same channel
+type Server struct {
+	// Topic is what Get answers with
+	Topic string
+	// Log gets a line whenever a client comes or goes, nil keeps quiet
+	Log *log.Logger
+
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	nextid  uint32
+	clients map[*client]struct{}
+}
+
+// client is one connection. the identity fields are whatever its last Set
+// said, active is the id of the message it is typing, and backlog is every
+// event of that message so far so that someone who joins halfway through it
+// sees what was already typed
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	// dropped is set once send has been closed
+	dropped bool
+
+	nick       *string
+	externalID *string
+	color      *uint32
+
+	active  *uint32
+	backlog [][]byte
+}
+
+func New(topic string) *Server {
+	return &Server{
+		Topic: topic,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{"lrc.v1"},
+			// there are no cookies or credentials to protect, so browser
+			// clients from anywhere are welcome
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		return
+	}
+	s.logf("%s joined", r.RemoteAddr)
+	s.serve(conn)
+	s.logf("%s left", r.RemoteAddr)
+}
+
+// Connected returns how many clients are in the channel
+func (s *Server) Connected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Log != nil {
+		s.Log.Printf(format, v...)
+	}
+}
+
+func (s *Server) serve(conn *websocket.Conn) {
+	c := &client{
+		conn: conn,
+		send: make(chan []byte, sendBuffer),
+	}
+	go c.write()
+	s.join(c)
+	defer s.leave(c)
+	conn.SetReadLimit(maxEventSize)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var e lrcpb.Event
+		err = proto.Unmarshal(data, &e)
+		if err != nil {
+			s.logf("%s sent garbage: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		s.handle(c, &e)
+	}
+}
+
+// write sends everything queued for c until it is dropped
+func (c *client) write() {
+	for data := range c.send {
+		err := c.conn.WriteMessage(websocket.BinaryMessage, data)
+		if err != nil {
+			break
+		}
+	}
+	c.conn.Close()
+}
+
+// join adds c to the channel and catches it up on the messages that are
+// being typed right now
+func (s *Server) join(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = struct{}{}
+	for other := range s.clients {
+		for _, data := range other.backlog {
+			s.queue(c, data)
+		}
+	}
+}
+
+// leave removes c, publishing whatever it was in the middle of so that
+// nobody is left watching a message that will never finish
+func (s *Server) leave(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.active != nil {
+		s.pub(c)
+	}
+	delete(s.clients, c)
+	s.drop(c)
+}
+
+// queue hands data to c's writer, dropping c if it has fallen too far behind.
+// s.mu must be held
+func (s *Server) queue(c *client, data []byte) {
+	if c.dropped {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		s.logf("%s fell behind, dropping it", c.conn.RemoteAddr())
+		delete(s.clients, c)
+		s.drop(c)
+		// its writer is most likely stuck on a peer that isn't reading, so
+		// hang up rather than wait for it
+		c.conn.Close()
+	}
+}
+
+func (s *Server) drop(c *client) {
+	if c.dropped {
+		return
+	}
+	c.dropped = true
+	close(c.send)
+}
+
+// broadcast sends e to every client. s.mu must be held
+func (s *Server) broadcast(e *lrcpb.Event) []byte {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return nil
+	}
+	for other := range s.clients {
+		s.queue(other, data)
+	}
+	return data
+}
+
+// reply sends e to c alone. s.mu must be held
+func (s *Server) reply(c *client, e *lrcpb.Event) {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.queue(c, data)
+}
+
+func (s *Server) handle(c *client, e *lrcpb.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch msg := e.Msg.(type) {
+	case *lrcpb.Event_Ping:
+		s.reply(c, &lrcpb.Event{Msg: &lrcpb.Event_Pong{Pong: &lrcpb.Pong{}}})
+	case *lrcpb.Event_Init:
+		if c.active != nil {
+			s.pub(c)
+		}
+		s.init(c, msg.Init)
+	case *lrcpb.Event_Insert:
+		id := s.ensureActive(c)
+		msg.Insert.Id = &id
+		s.edit(c, &lrcpb.Event{Id: &id, Msg: msg})
+	case *lrcpb.Event_Delete:
+		id := s.ensureActive(c)
+		msg.Delete.Id = &id
+		s.edit(c, &lrcpb.Event{Id: &id, Msg: msg})
+	case *lrcpb.Event_Editbatch:
+		id := s.ensureActive(c)
+		for _, edit := range msg.Editbatch.GetEdits() {
+			switch edit := edit.Edit.(type) {
+			case *lrcpb.Edit_Insert:
+				edit.Insert.Id = &id
+			case *lrcpb.Edit_Delete:
+				edit.Delete.Id = &id
+			}
+		}
+		s.edit(c, &lrcpb.Event{Id: &id, Msg: msg})
+	case *lrcpb.Event_Pub:
+		if c.active != nil {
+			s.pub(c)
+		}
+	case *lrcpb.Event_Set:
+		c.nick = msg.Set.Nick
+		c.externalID = msg.Set.ExternalID
+		c.color = msg.Set.Color
+		if c.active != nil {
+			id := *c.active
+			s.edit(c, &lrcpb.Event{Id: &id, Msg: msg})
+		}
+	case *lrcpb.Event_Get:
+		topic := s.Topic
+		connected := uint32(len(s.clients))
+		s.reply(c, &lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: &topic, Connected: &connected}}})
+	}
+}
+
+// init starts a new message for c. the sender gets its init echoed back with
+// the nonce it sent so that it can tell which message is its own, everyone
+// else gets it without. s.mu must be held
+func (s *Server) init(c *client, init *lrcpb.Init) {
+	s.nextid++
+	id := s.nextid
+	c.active = &id
+	out := &lrcpb.Init{
+		Id:         &id,
+		Nick:       c.nick,
+		ExternalID: c.externalID,
+		Color:      c.color,
+	}
+	if init != nil {
+		if init.Nick != nil {
+			out.Nick = init.Nick
+		}
+		if init.ExternalID != nil {
+			out.ExternalID = init.ExternalID
+		}
+		if init.Color != nil {
+			out.Color = init.Color
+		}
+	}
+	data, err := proto.Marshal(&lrcpb.Event{Id: &id, Msg: &lrcpb.Event_Init{Init: out}})
+	if err != nil {
+		return
+	}
+	c.backlog = [][]byte{data}
+	for other := range s.clients {
+		if other != c {
+			s.queue(other, data)
+		}
+	}
+	echo := proto.Clone(out).(*lrcpb.Init)
+	echoed := true
+	echo.Echoed = &echoed
+	if init != nil {
+		echo.Nonce = init.Nonce
+	}
+	s.reply(c, &lrcpb.Event{Id: &id, Msg: &lrcpb.Event_Init{Init: echo}})
+}
+
+// ensureActive returns the id of the message c is typing, starting one if
+// it went straight to typing without an init. s.mu must be held
+func (s *Server) ensureActive(c *client) uint32 {
+	if c.active == nil {
+		s.init(c, nil)
+	}
+	return *c.active
+}
+
+// edit relays a change to c's active message to everyone, c included, and
+// keeps it for anyone who joins before the message is published. s.mu must
+// be held
+func (s *Server) edit(c *client, e *lrcpb.Event) {
+	data := s.broadcast(e)
+	if data != nil {
+		c.backlog = append(c.backlog, data)
+	}
+}
+
+// pub finishes c's active message. s.mu must be held
+func (s *Server) pub(c *client) {
+	id := *c.active
+	s.broadcast(&lrcpb.Event{Id: &id, Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{Id: &id}}})
+	c.active = nil
+	c.backlog = nil
+}
@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"google.golang.org/protobuf/proto"
+)
+
+const topic = "the topic"
+
+// start runs a relay and returns it with its websocket url
+func start(t *testing.T) (*Server, string) {
+	s := New(topic)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// connect joins the relay as nick and waits until the relay has it, so that
+// nothing sent after it is missed
+func connect(t *testing.T, s *Server, url string, nick string) *lrcclient.Client {
+	t.Helper()
+	want := s.Connected() + 1
+	c, err := lrcclient.Connect(context.Background(), url, lrcclient.Config{Nick: &nick})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	waitFor(t, func() bool { return s.Connected() == want })
+	return c
+}
+
+// dial joins the relay with a bare websocket
+func dial(t *testing.T, s *Server, url string) *websocket.Conn {
+	t.Helper()
+	want := s.Connected() + 1
+	dialer := websocket.Dialer{Subprotocols: []string{"lrc.v1"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitFor(t, func() bool { return s.Connected() == want })
+	return conn
+}
+
+func waitFor(t *testing.T, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatal("gave up waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// next returns the next event of the given kind that c gets
+func next(t *testing.T, c *lrcclient.Client, kind lrcclient.Kind) lrcclient.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-c.Events():
+			if !ok {
+				t.Fatalf("client stopped: %v", c.Err())
+			}
+			if ev.Kind == kind {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no event of kind %d", kind)
+		}
+	}
+}
+
+func write(t *testing.T, conn *websocket.Conn, e *lrcpb.Event) {
+	t.Helper()
+	data, err := proto.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, conn *websocket.Conn) *lrcpb.Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e lrcpb.Event
+	err = proto.Unmarshal(data, &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &e
+}
+
+func TestRelayInit(t *testing.T) {
+	s, url := start(t)
+	ann := connect(t, s, url, "ann")
+	bob := connect(t, s, url, "bob")
+	raw := dial(t, s, url)
+
+	// whoever starts a message gets the init back with its nonce, everyone
+	// else gets it without
+	nonce, nick := []byte("n1"), "raw"
+	write(t, raw, &lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{Nonce: nonce, Nick: &nick}}})
+	echo := read(t, raw).GetInit()
+	if echo == nil || !echo.GetEchoed() || !bytes.Equal(echo.GetNonce(), nonce) || echo.GetId() != 1 || echo.GetNick() != nick {
+		t.Errorf("sender got %v, want init 1 echoed with nonce %s", echo, nonce)
+	}
+	for _, c := range []*lrcclient.Client{ann, bob} {
+		init := next(t, c, lrcclient.KindInit)
+		if in := init.Raw.GetInit(); in.GetId() != 1 || in.Echoed != nil || in.Nonce != nil || in.GetNick() != nick {
+			t.Errorf("others got %v, want init 1 without echoed or nonce", in)
+		}
+		if init.Message.Mine {
+			t.Error("someone else's message is mine")
+		}
+	}
+
+	// ids keep counting up, and ann's are hers alone
+	err := ann.Send("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, ann, lrcclient.KindInit).Message; m.ID != 2 || !m.Mine || *m.Nick != "ann" {
+		t.Errorf("ann got %+v, want her own message 2", m)
+	}
+	if m := next(t, bob, lrcclient.KindInit).Message; m.ID != 2 || m.Mine || *m.Nick != "ann" {
+		t.Errorf("bob got %+v, want ann's message 2", m)
+	}
+	if m := next(t, bob, lrcclient.KindPub).Message; m.Text != "hi" || m.Active {
+		t.Errorf("bob got %+v, want hi pub'd", m)
+	}
+	err = bob.Edit("yo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, ann, lrcclient.KindInit).Message; m.ID != 3 || *m.Nick != "bob" {
+		t.Errorf("ann got %+v, want bob's message 3", m)
+	}
+}
+
+func TestRelayBacklog(t *testing.T) {
+	s, url := start(t)
+	ann := connect(t, s, url, "ann")
+	err := ann.Edit("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next(t, ann, lrcclient.KindEdit)
+
+	// cat joins halfway through ann's message and still sees all of it
+	cat := connect(t, s, url, "cat")
+	init := next(t, cat, lrcclient.KindInit)
+	if m := init.Message; *m.Nick != "ann" || m.Mine {
+		t.Errorf("cat got %+v, want ann's message", m)
+	}
+	if m := next(t, cat, lrcclient.KindEdit).Message; m.Text != "hello" || !m.Active {
+		t.Errorf("cat got %+v, want hello being typed", m)
+	}
+	err = ann.Edit("hello all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, cat, lrcclient.KindEdit).Message; m.Text != "hello all" {
+		t.Errorf("cat got %+v, want hello all", m)
+	}
+	err = ann.Publish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next(t, cat, lrcclient.KindPub)
+
+	// a message that is done isn't played to anyone who joins after it
+	dan := dial(t, s, url)
+	write(t, dan, &lrcpb.Event{Msg: &lrcpb.Event_Ping{Ping: &lrcpb.Ping{}}})
+	if e := read(t, dan); e.GetPong() == nil {
+		t.Errorf("late joiner got %v before its pong", e)
+	}
+}
+
+func TestRelayGet(t *testing.T) {
+	s, url := start(t)
+	connect(t, s, url, "ann")
+	bob := connect(t, s, url, "bob")
+	// the get that every client sends when it starts may have been answered
+	// before ann joined, so ask again
+	raw := dial(t, s, url)
+	bep := "bep"
+	write(t, raw, &lrcpb.Event{Msg: &lrcpb.Event_Get{Get: &lrcpb.Get{Topic: &bep}}})
+	get := read(t, raw).GetGet()
+	if get.GetTopic() != topic || get.GetConnected() != 3 {
+		t.Errorf("got %v, want topic %q with 3 connected", get, topic)
+	}
+	next(t, bob, lrcclient.KindGet)
+	if got, ok := bob.Topic(); !ok || got != topic {
+		t.Errorf("bob's topic is %q, want %q", got, topic)
+	}
+}
+
+// TestRelaySlowReader has one client that never reads while another sends
+// far more than fits in the buffers between them, a message at a time. the
+// one that reads keeps getting everything and the one that doesn't gets
+// dropped
+func TestRelaySlowReader(t *testing.T) {
+	s, url := start(t)
+	ann := connect(t, s, url, "ann")
+	bob := connect(t, s, url, "bob")
+	slow := dial(t, s, url)
+	go func() {
+		for range ann.Events() {
+		}
+	}()
+
+	const n = 600
+	body := strings.Repeat("x", 32<<10)
+	for i := range n {
+		err := ann.Send(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := next(t, bob, lrcclient.KindPub).Message; len(m.Text) != len(body) {
+			t.Fatalf("message %d is %d bytes, want %d", i, len(m.Text), len(body))
+		}
+	}
+	if got := s.Connected(); got != 2 {
+		t.Errorf("%d are connected, want the 2 that kept up", got)
+	}
+	// and the slow one is hung up on rather than left hanging
+	slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := slow.ReadMessage()
+		if err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				t.Error("the slow client is still connected")
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+	"github.com/rachel-mp4/ttyxcvr/record"
+	"google.golang.org/protobuf/proto"
+)
+
+var replaySpeeds = []float64{0.5, 1, 2, 4, 8, 16}
+
+// replaySeek is how far the arrow keys move through a replay
+const replaySeek = 5 * time.Second
+
+// replaymodel plays a capture made with --record into a channelmodel as if
+// the frames were arriving over the network. only inbound frames are played,
+// everything we sent shows up again as the relay's echo
+type replaymodel struct {
+	name   string
+	frames []*record.Frame
+	// pos is the index of the next frame to play
+	pos    int
+	speed  int
+	paused bool
+	// gen is bumped whenever the schedule changes so that ticks that were
+	// already on their way for the old schedule get ignored
+	gen int
+	err error
+	// lrc materializes the frames the way a live client would before they
+	// are handed to the channel
+	lrc *lrcclient.Store
+	cm  *channelmodel
+	gsd *globalsettingsdata
+}
+
+type replayTickMsg struct {
+	gen int
+}
+
+func replayMain(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "playback speed, one of 0.5, 1, 2, 4, 8 or 16")
+	paused := fs.Bool("paused", false, "start paused, step through with n")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr replay [flags] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	si := slices.Index(replaySpeeds, *speed)
+	if si < 0 {
+		fmt.Printf("%v isn't a replay speed\n", *speed)
+		return 2
+	}
+	path := fs.Arg(0)
+	frames, err := record.ReadAll(path)
+	if err != nil && len(frames) == 0 {
+		fmt.Printf("couldn't read %s: %v\n", path, err)
+		return 1
+	}
+	inbound := make([]*record.Frame, 0, len(frames))
+	for _, f := range frames {
+		if f.Direction == record.In {
+			inbound = append(inbound, f)
+		}
+	}
+	config, _ := loadConfig()
+	nick := "wanderer"
+	gsd := &globalsettingsdata{
+		nick:   &nick,
+		width:  30,
+		height: 20,
+		state:  Connected,
+	}
+	config.apply(gsd)
+	// a recording happened already, running hooks on it again would notify
+	// people about things that are long over
+	gsd.hooks = nil
+	rm := &replaymodel{
+		name:   filepath.Base(path),
+		frames: inbound,
+		speed:  si,
+		paused: *paused,
+		err:    err,
+		gsd:    gsd,
+	}
+	rm.reset()
+	p := tea.NewProgram(rm, tea.WithAltScreen())
+	gsd.send = p.Send
+	_, err = p.Run()
+	rm.cm.store.Close()
+	if err != nil {
+		fmt.Printf("Alas, there's been an error: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// reset goes back to before the first frame
+func (rm *replaymodel) reset() {
+	if rm.cm != nil {
+		rm.cm.store.Close()
+	}
+	cm := newChannelModel(rm.gsd, "replay://"+rm.name)
+	cm.draft.Placeholder = "replaying " + rm.name
+	rm.cm = &cm
+	rm.lrc = lrcclient.NewStore(rm.gsd.scrollback)
+	rm.pos = 0
+}
+
+// now is how far into the capture we are
+func (rm *replaymodel) now() time.Duration {
+	if rm.pos == 0 {
+		return 0
+	}
+	return rm.frames[rm.pos-1].Time
+}
+
+func (rm *replaymodel) length() time.Duration {
+	if len(rm.frames) == 0 {
+		return 0
+	}
+	return rm.frames[len(rm.frames)-1].Time
+}
+
+// play hands the next frame to the channel
+func (rm *replaymodel) play() tea.Cmd {
+	if rm.pos >= len(rm.frames) {
+		return nil
+	}
+	f := rm.frames[rm.pos]
+	rm.pos++
+	msg, err := rm.frameMsg(f)
+	if err != nil {
+		rm.err = err
+		return nil
+	}
+	cm, cmd, err := rm.cm.updateConnected(msg)
+	if err != nil {
+		rm.err = err
+		return nil
+	}
+	rm.cm = &cm
+	return cmd
+}
+
+// frameMsg turns a captured frame back into what listenToClient or
+// listenToLexConn would have sent for it
+func (rm *replaymodel) frameMsg(f *record.Frame) (tea.Msg, error) {
+	if f.LRC != nil {
+		var e lrcpb.Event
+		err := proto.Unmarshal(f.LRC, &e)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := rm.lrc.Apply(&e)
+		if err != nil {
+			return nil, err
+		}
+		return lrcEvent{ev, nil}, nil
+	}
+	var lsm lex.SubscribeLexStream_Message
+	err := json.Unmarshal(f.Lex, &lsm)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case lsm.SignetView != nil:
+		return svMsg{lsm.SignetView}, nil
+	case lsm.MessageView != nil:
+		return mvMsg{lsm.MessageView}, nil
+	}
+	return nil, nil
+}
+
+// seek plays or rewinds to the last frame at or before t without waiting
+func (rm *replaymodel) seek(t time.Duration) {
+	if t < rm.now() {
+		rm.reset()
+	}
+	for rm.pos < len(rm.frames) && rm.frames[rm.pos].Time <= t {
+		rm.play()
+	}
+}
+
+// schedule waits for the next frame to be due at the current speed
+func (rm *replaymodel) schedule() tea.Cmd {
+	rm.gen++
+	if rm.paused || rm.pos >= len(rm.frames) {
+		return nil
+	}
+	wait := rm.frames[rm.pos].Time - rm.now()
+	wait = time.Duration(float64(wait) / replaySpeeds[rm.speed])
+	gen := rm.gen
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return replayTickMsg{gen}
+	})
+}
+
+func (rm *replaymodel) Init() tea.Cmd {
+	return rm.schedule()
+}
+
+func (rm *replaymodel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case replayTickMsg:
+		if msg.gen != rm.gen {
+			return rm, nil
+		}
+		cmd := rm.play()
+		return rm, tea.Batch(cmd, rm.schedule())
+	case tea.WindowSizeMsg:
+		rm.gsd.width = msg.Width
+		rm.gsd.height = msg.Height
+		rm.cm.vp.Width = msg.Width
+		rm.cm.vp.Height = msg.Height - 2
+		rm.cm.store.TouchAll()
+		rm.cm.redraw()
+		return rm, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return rm, tea.Quit
+		case " ", "p":
+			rm.paused = !rm.paused
+			return rm, rm.schedule()
+		case "+", "=":
+			rm.speed = min(len(replaySpeeds)-1, rm.speed+1)
+			return rm, rm.schedule()
+		case "-", "_":
+			rm.speed = max(0, rm.speed-1)
+			return rm, rm.schedule()
+		case "n", ".":
+			rm.paused = true
+			cmd := rm.play()
+			return rm, tea.Batch(cmd, rm.schedule())
+		case "right", "l":
+			rm.seek(rm.now() + replaySeek)
+			return rm, rm.schedule()
+		case "left", "h":
+			rm.seek(rm.now() - replaySeek)
+			return rm, rm.schedule()
+		case "home", "0":
+			rm.seek(-1)
+			return rm, rm.schedule()
+		case "end", "$":
+			rm.seek(rm.length())
+			return rm, rm.schedule()
+		}
+		vp, cmd := rm.cm.vp.Update(msg)
+		rm.cm.vp = vp
+		return rm, cmd
+	}
+	return rm, nil
+}
+
+func (rm *replaymodel) View() string {
+	state := "playing"
+	switch {
+	case rm.pos >= len(rm.frames):
+		state = "finished"
+	case rm.paused:
+		state = "paused"
+	}
+	status := fmt.Sprintf("%s %s %s/%s %s ×%g %s event %d/%d",
+		rm.name, bullet, clock(rm.now()), clock(rm.length()), bullet, replaySpeeds[rm.speed], bullet, rm.pos, len(rm.frames))
+	status = fmt.Sprintf("%s %s %s", status, bullet, state)
+	if rm.err != nil {
+		status = fmt.Sprintf("%s %s %s", status, bullet, rm.err.Error())
+	}
+	if pad := rm.gsd.width - lipgloss.Width(status); pad > 0 {
+		status += strings.Repeat(" ", pad)
+	}
+	footer := lipgloss.NewStyle().Reverse(true).Inline(true).MaxWidth(rm.gsd.width).Render(status)
+	keys := subduedStyle.Inline(true).MaxWidth(rm.gsd.width).
+		Render(strings.Join([]string{"space pause", "+/- speed", "←/→ seek", "n step", "0/$ start/end", "q quit"}, " "+bullet+" "))
+	return fmt.Sprintf("%s\n%s\n%s", rm.cm.vp.View(), footer, keys)
+}
+
+// clock formats d as minutes and seconds to a tenth of a second
+func clock(d time.Duration) string {
+	d = d.Round(100 * time.Millisecond)
+	return fmt.Sprintf("%02d:%04.1f", int(d.Minutes()), (d % time.Minute).Seconds())
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"github.com/rachel-mp4/ttyxcvr/record"
+	"google.golang.org/protobuf/proto"
+)
+
+// gap is how far apart the frames of the test capture are
+const gap = 1600 * time.Millisecond
+
+// replayFrames is a capture of ann typing and pub'ing a message, getting a
+// signet for it along the way, while bob starts one of his own
+func replayFrames(t *testing.T) []*record.Frame {
+	ann, bob := uint32(1), uint32(2)
+	annNick, bobNick := "ann", "bob"
+	events := []proto.Message{
+		&lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{Id: &ann, Nick: &annNick}}},
+		&lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Id: &ann, Body: "hi"}}},
+		nil,
+		&lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{Id: &bob, Nick: &bobNick}}},
+		&lrcpb.Event{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{Id: &ann}}},
+		&lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Id: &bob, Body: "yo"}}},
+	}
+	frames := make([]*record.Frame, 0, len(events))
+	for i, e := range events {
+		f := &record.Frame{Time: time.Duration(i) * gap}
+		if e == nil {
+			f.Lex = []byte(`{"$type":"org.xcvr.lrc.defs#signetView","uri":"at://did:plc:host/org.xcvr.lrc.signet/1","lrcID":1,"authorHandle":"ann.test"}`)
+		} else {
+			data, err := proto.Marshal(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.LRC = data
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func newReplay(t *testing.T) *replaymodel {
+	nick := "wanderer"
+	rm := &replaymodel{
+		name:   "test",
+		frames: replayFrames(t),
+		speed:  slices.Index(replaySpeeds, 16),
+		gsd:    &globalsettingsdata{nick: &nick, width: 30, height: 20, state: Connected},
+	}
+	rm.reset()
+	t.Cleanup(func() { rm.cm.store.Close() })
+	return rm
+}
+
+// replayed describes the transcript so far, * for a message still being
+// typed and ✓ for a signed one
+func replayed(rm *replaymodel) string {
+	var s string
+	for i := range rm.cm.store.Len() {
+		m := rm.cm.store.At(i)
+		s += fmt.Sprintf("[%s: %s", *m.nick, m.text)
+		if m.active {
+			s += "*"
+		}
+		if m.signet != nil {
+			s += "✓"
+		}
+		s += "]"
+	}
+	return s
+}
+
+var replaySteps = []string{
+	"[ann: *]",
+	"[ann: hi*]",
+	"[ann: hi*✓]",
+	"[ann: hi*✓][bob: *]",
+	"[ann: hi✓][bob: *]",
+	"[ann: hi✓][bob: yo*]",
+}
+
+func TestReplayPlays(t *testing.T) {
+	rm := newReplay(t)
+	var got []string
+	start := time.Now()
+	cmds := []tea.Cmd{rm.Init()}
+	for len(cmds) > 0 {
+		cmd := cmds[0]
+		cmds = cmds[1:]
+		if cmd == nil {
+			continue
+		}
+		switch msg := cmd().(type) {
+		case tea.BatchMsg:
+			cmds = append(cmds, msg...)
+		case replayTickMsg:
+			_, next := rm.Update(msg)
+			got = append(got, replayed(rm))
+			cmds = append(cmds, next)
+		}
+	}
+	elapsed := time.Since(start)
+	if !slices.Equal(got, replaySteps) {
+		t.Errorf("played\n%q\nwant\n%q", got, replaySteps)
+	}
+	// the capture is 8s long and plays at 16 times that
+	if want := rm.length() / 16; elapsed < want || elapsed > want+time.Second {
+		t.Errorf("played in %v, want about %v", elapsed, want)
+	}
+	if rm.err != nil {
+		t.Error(rm.err)
+	}
+}
+
+func TestReplaySeek(t *testing.T) {
+	rm := newReplay(t)
+	rm.seek(3 * gap)
+	if rm.pos != 4 || replayed(rm) != replaySteps[3] {
+		t.Errorf("seeking to %v got to frame %d with %s", 3*gap, rm.pos, replayed(rm))
+	}
+	// back to between frames goes to the one before
+	rm.seek(gap + gap/2)
+	if rm.pos != 2 || rm.now() != gap || replayed(rm) != replaySteps[1] {
+		t.Errorf("seeking back got to frame %d at %v with %s", rm.pos, rm.now(), replayed(rm))
+	}
+	rm.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if rm.pos != 5 || replayed(rm) != replaySteps[4] {
+		t.Errorf("seeking forward got to frame %d with %s", rm.pos, replayed(rm))
+	}
+	rm.Update(tea.KeyMsg{Type: tea.KeyEnd})
+	if rm.pos != len(rm.frames) || replayed(rm) != replaySteps[5] {
+		t.Errorf("seeking to the end got to frame %d with %s", rm.pos, replayed(rm))
+	}
+	rm.Update(tea.KeyMsg{Type: tea.KeyHome})
+	if rm.pos != 0 || replayed(rm) != "" {
+		t.Errorf("seeking to the start got to frame %d with %s", rm.pos, replayed(rm))
+	}
+
+	// a tick that was scheduled before the seek doesn't play anything
+	rm.Update(replayTickMsg{rm.gen - 1})
+	if rm.pos != 0 {
+		t.Errorf("a stale tick played frame %d", rm.pos)
+	}
+}
+
+func TestReplayStep(t *testing.T) {
+	rm := newReplay(t)
+	step := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}
+	for i, want := range replaySteps {
+		rm.Update(step)
+		if !rm.paused {
+			t.Fatal("stepping didn't pause")
+		}
+		if got := replayed(rm); got != want {
+			t.Errorf("step %d got %s, want %s", i, got, want)
+		}
+	}
+	rm.Update(step)
+	if rm.pos != len(rm.frames) || replayed(rm) != replaySteps[len(replaySteps)-1] {
+		t.Errorf("stepping past the end got to frame %d with %s", rm.pos, replayed(rm))
+	}
+}
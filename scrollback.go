@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scrollbackPage is how many messages are read back from disk at a time when
+// scrolling past the top of what is in memory
+const scrollbackPage = 100
+
+// spill is the on-disk end of a MessageStore's scrollback. messages are
+// appended to it as json lines when they are evicted and read back by
+// sequence number, a message that changed after being paged back in is
+// appended again rather than rewritten in place. the file only lives as long
+// as the store does
+type spill struct {
+	f       *os.File
+	records []spillRecord
+	size    int64
+}
+
+type spillRecord struct {
+	offset int64
+	length int
+}
+
+func newSpill(channel string) (*spill, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "scrollback")
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, spillName(channel)+"-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	return &spill{f: f}, nil
+}
+
+// spillName turns a channel url into something that is safe to use as part of
+// a file name
+func spillName(channel string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, channel)
+}
+
+func (sp *spill) write(seq int, m *Message) error {
+	if seq > len(sp.records) {
+		return errors.New("scrollback is missing messages")
+	}
+	// only published entries get evicted, so there is nothing left in the
+	// outbox entry worth keeping
+	mj := m.json()
+	mj.Outbox = nil
+	data, err := json.Marshal(mj)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = sp.f.WriteAt(data, sp.size)
+	if err != nil {
+		return err
+	}
+	rec := spillRecord{sp.size, len(data)}
+	sp.size += int64(len(data))
+	if seq == len(sp.records) {
+		sp.records = append(sp.records, rec)
+	} else {
+		sp.records[seq] = rec
+	}
+	return nil
+}
+
+func (sp *spill) read(seq int) (*Message, error) {
+	if seq < 0 || seq >= len(sp.records) {
+		return nil, errors.New("scrollback is missing messages")
+	}
+	rec := sp.records[seq]
+	data := make([]byte, rec.length)
+	_, err := sp.f.ReadAt(data, rec.offset)
+	if err != nil {
+		return nil, err
+	}
+	var mj messageJSON
+	err = json.Unmarshal(data, &mj)
+	if err != nil {
+		return nil, err
+	}
+	m := mj.message()
+	m.dirty = true
+	m.spilled = true
+	return m, nil
+}
+
+func (sp *spill) close() error {
+	err := sp.f.Close()
+	return errors.Join(err, os.Remove(sp.f.Name()))
+}
+
+// SetScrollback changes how many messages are kept in memory, the excess is
+// spilled the next time the transcript is refreshed
+func (s *MessageStore) SetScrollback(limit int) {
+	s.limit = limit
+}
+
+// evictable reports whether a message is finished with and can be moved to
+// disk without losing anything that is only kept in memory
+func (m *Message) evictable() bool {
+	return !m.active && !m.selected && (m.outbox == nil || m.outbox.snapshot().State == OutboxPublished)
+}
+
+// trim spills messages from the top of the store until it is within its
+// limit, but never one that reaches down to line above, so that whatever is on
+// screen stays put. it returns how many lines were removed from the top
+func (s *MessageStore) trim(above int) int {
+	if s.limit <= 0 || len(s.msgs) <= s.limit {
+		return 0
+	}
+	s.layout()
+	n := 0
+	removed := 0
+	for len(s.msgs)-n > s.limit {
+		m := s.msgs[n]
+		if !m.evictable() || s.lines[n]+len(m.lines) > above {
+			break
+		}
+		if !m.spilled {
+			if s.spill == nil {
+				sp, err := newSpill(s.channel)
+				if err != nil {
+					break
+				}
+				s.spill = sp
+			}
+			if s.spill.write(s.base+n, m) != nil {
+				break
+			}
+			m.spilled = true
+		}
+		removed += len(m.lines)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	for i, m := range s.msgs[:n] {
+		// a message that was paged back in might have had its id reused
+		// since, which stays with the newer message
+		if s.ids[m.id] == s.base+i {
+			delete(s.ids, m.id)
+		}
+		s.msgs[i] = nil
+	}
+	s.msgs = s.msgs[n:]
+	s.lines = s.lines[n:]
+	s.base += n
+	s.stale = 0
+	return removed
+}
+
+// PageIn reads up to n of the messages just above the top of the store back
+// from disk and returns how many lines they added to the top
+func (s *MessageStore) PageIn(n int) int {
+	if s.spill == nil || s.base == 0 {
+		return 0
+	}
+	n = min(n, s.base)
+	paged := make([]*Message, 0, n)
+	for seq := s.base - n; seq < s.base; seq++ {
+		m, err := s.spill.read(seq)
+		if err != nil {
+			return 0
+		}
+		paged = append(paged, m)
+	}
+	s.base -= n
+	// newest first, so that an id that was reused stays with the newest
+	// message that has it
+	for i := len(paged) - 1; i >= 0; i-- {
+		if _, ok := s.ids[paged[i].id]; !ok {
+			s.ids[paged[i].id] = s.base + i
+		}
+	}
+	s.msgs = append(paged, s.msgs...)
+	s.lines = append(make([]int, n), s.lines...)
+	s.stale = 0
+	s.layout()
+	added := 0
+	for _, m := range paged {
+		added += len(m.lines)
+	}
+	return added
+}
+
+// Close removes anything the store spilled to disk
+func (s *MessageStore) Close() error {
+	if s.spill == nil {
+		return nil
+	}
+	err := s.spill.close()
+	s.spill = nil
+	return err
+}
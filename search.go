@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+type searchmodel struct {
+	query   string
+	list    list.Model
+	history *viewport.Model
+	// prev is the state to go back to once we are done searching
+	prev txstate
+	gsd  *globalsettingsdata
+}
+
+type ResultItem struct {
+	result SearchResult
+}
+
+func (r ResultItem) FilterValue() string {
+	return r.result.Message.Body
+}
+
+type ResultItemDelegate struct{}
+
+func (d ResultItemDelegate) Height() int                                  { return 2 }
+func (d ResultItemDelegate) Spacing() int                                 { return 1 }
+func (d ResultItemDelegate) Update(msg tea.Msg, list *list.Model) tea.Cmd { return nil }
+func (d ResultItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	i, ok := item.(ResultItem)
+	if !ok {
+		return
+	}
+	am := i.result.Message
+	channel := am.Channel
+	if am.Title != nil {
+		channel = *am.Title
+	}
+	where := subduedStyle.Render(fmt.Sprintf("in %s %s %s", channel, bullet, am.Time.Local().Format("2006-01-02 15:04")))
+	body := strings.ReplaceAll(am.Body, "\n", " ")
+	if runes := []rune(body); len(runes) > m.Width()-2 && m.Width() > 3 {
+		body = string(runes[:m.Width()-3]) + ellipsis
+	}
+	name := renderName(am.Nick, am.Handle)
+	if index == m.Index() {
+		name = lipgloss.NewStyle().Foreground(ColorFromInt(am.Color)).Render(name)
+		fmt.Fprintf(w, "│%s %s\n│%s", name, where, body)
+		return
+	}
+	fmt.Fprintf(w, " %s %s\n %s", subduedStyle.Render(name), where, body)
+}
+
+// archive records msg in the local archive now that it has been pub'd
+func (cm *channelmodel) archive(msg *Message) tea.Cmd {
+	if msg == nil || msg.text == "" {
+		return nil
+	}
+	am := &ArchivedMessage{
+		Channel: cm.wsurl,
+		WSURL:   cm.wsurl,
+		Time:    time.Now(),
+		LrcID:   msg.id,
+		Nick:    msg.nick,
+		Handle:  msg.handle,
+		Color:   msg.color,
+		Body:    msg.text,
+	}
+	if cm.channel != nil {
+		am.Channel = cm.channel.URI
+		am.Title = &cm.channel.Title
+	}
+	if msg.signet != nil {
+		am.Handle = &msg.signet.AuthorHandle
+		am.SignetURI = &msg.signet.URI
+	}
+	err := cm.gsd.archive.add(am)
+	if err != nil {
+		return func() tea.Msg { return archiveFailedMsg{err} }
+	}
+	return nil
+}
+
+// message turns am back into something that can be rendered like a live
+// message
+func (am *ArchivedMessage) message() *Message {
+	m := &Message{
+		id:     am.LrcID,
+		nick:   am.Nick,
+		handle: am.Handle,
+		color:  am.Color,
+		text:   am.Body,
+	}
+	if am.SignetURI != nil && am.Handle != nil {
+		m.signet = &lex.SignetView{URI: *am.SignetURI, AuthorHandle: *am.Handle}
+	}
+	return m
+}
+
+func searchCmd(a *Archive, query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := a.Search(query)
+		return searchResultsMsg{query, results, err}
+	}
+}
+
+func historyCmd(a *Archive, r SearchResult) tea.Cmd {
+	return func() tea.Msg {
+		msgs, at, err := a.around(r)
+		return historyMsg{msgs, at, err}
+	}
+}
+
+type searchMsg struct {
+	query string
+}
+
+type searchResultsMsg struct {
+	query   string
+	results []SearchResult
+	err     error
+}
+
+type historyMsg struct {
+	msgs []*ArchivedMessage
+	at   int
+	err  error
+}
+
+type archiveFailedMsg struct {
+	err error
+}
+
+func (m model) startSearch(query string) (tea.Model, tea.Cmd) {
+	prev := m.gsd.state
+	if m.sm != nil {
+		prev = m.sm.prev
+	}
+	m.sm = &searchmodel{query: query, prev: prev, gsd: m.gsd}
+	m.gsd.state = Searching
+	return m, searchCmd(m.gsd.archive, query)
+}
+
+func (m model) updateSearching(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case searchResultsMsg:
+		if msg.err != nil {
+			out := "couldn't search: " + msg.err.Error()
+			m.cmdout = &out
+			return m.endSearch(), nil
+		}
+		if len(msg.results) == 0 {
+			out := fmt.Sprintf("nothing in the archive matches %q", msg.query)
+			m.cmdout = &out
+			return m.endSearch(), nil
+		}
+		items := make([]list.Item, 0, len(msg.results))
+		for _, r := range msg.results {
+			items = append(items, ResultItem{r})
+		}
+		l := list.New(items, ResultItemDelegate{}, m.gsd.width, m.gsd.height-1)
+		l.Styles = defaultStyles()
+		l.Title = fmt.Sprintf("search %q %s %d results", msg.query, bullet, len(msg.results))
+		l.SetFilteringEnabled(false)
+		m.sm.list = l
+		m.gsd.state = SearchResults
+		return m, nil
+	}
+	return m.forwardToChannel(msg)
+}
+
+func (m model) updateSearchResults(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			return m.endSearch(), nil
+		case "enter":
+			if i, ok := m.sm.list.SelectedItem().(ResultItem); ok {
+				return m, historyCmd(m.gsd.archive, i.result)
+			}
+			return m, nil
+		}
+		l, cmd := m.sm.list.Update(msg)
+		m.sm.list = l
+		return m, cmd
+	case historyMsg:
+		if msg.err != nil {
+			out := "couldn't open history: " + msg.err.Error()
+			m.cmdout = &out
+			return m, nil
+		}
+		vp := viewport.New(m.gsd.width, m.gsd.height-1)
+		renders := make([]string, 0, len(msg.msgs))
+		line := 0
+		for i, am := range msg.msgs {
+			message := am.message()
+			message.selected = i == msg.at
+			r := message.renderMessage(m.gsd)
+			if i < msg.at {
+				line += strings.Count(r, "\n")
+			}
+			renders = append(renders, r)
+		}
+		vp.SetContent(strings.Join(renders, ""))
+		vp.SetYOffset(line - vp.Height/3)
+		m.sm.history = &vp
+		m.gsd.state = History
+		return m, nil
+	}
+	return m.forwardToChannel(msg)
+}
+
+func (m model) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			m.sm.history = nil
+			m.gsd.state = SearchResults
+			return m, nil
+		}
+		vp, cmd := m.sm.history.Update(msg)
+		m.sm.history = &vp
+		return m, cmd
+	}
+	return m.forwardToChannel(msg)
+}
+
+func (m model) endSearch() model {
+	m.gsd.state = m.sm.prev
+	m.sm = nil
+	return m
+}
+
+// forwardToChannel keeps the channel we are in up to date while we are off
+// looking at search results
+func (m model) forwardToChannel(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.cm == nil {
+		return m, nil
+	}
+	switch msg.(type) {
+	case tea.KeyMsg:
+		return m, nil
+	}
+	cm, cmd, err := m.cm.updateConnected(msg)
+	if err != nil {
+		m.gsd.state = Error
+		m.error = &err
+		return m, nil
+	}
+	m.cm = &cm
+	return m, cmd
+}
+
+func (sm searchmodel) searchView(cmding bool, prompt string) string {
+	if sm.history != nil {
+		footer := subduedStyle.Render("esc to go back to the results")
+		if cmding {
+			footer = prompt
+		}
+		return fmt.Sprintf("%s\n%s", sm.history.View(), footer)
+	}
+	cv := ""
+	if cmding {
+		cv = prompt
+	}
+	return fmt.Sprintf("%s\n%s", sm.list.View(), cv)
+}
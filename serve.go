@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/rachel-mp4/ttyxcvr/relay"
+)
+
+// serveMain hosts an lrc channel that can be joined with :dial
+func serveMain(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	topic := fs.String("topic", "", "what the channel is about, sent in reply to Get")
+	cert := fs.String("cert", "", "tls certificate `file`, serves wss instead of ws when given with --key")
+	key := fs.String("key", "", "tls key `file`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr serve [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*cert == "") != (*key == "") {
+		fs.Usage()
+		return 2
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	server := relay.New(*topic)
+	server.Log = logger
+	scheme := "ws"
+	if *cert != "" {
+		scheme = "wss"
+	}
+	logger.Printf("serving an lrc channel on %s, join with :dial %s://%s", *addr, scheme, dialHost(*addr))
+	var err error
+	if *cert != "" {
+		err = http.ListenAndServeTLS(*addr, *cert, *key, server)
+	} else {
+		err = http.ListenAndServe(*addr, server)
+	}
+	logger.Print(err)
+	return 1
+}
+
+// dialHost fills in localhost when addr doesn't name a host
+func dialHost(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
+	}
+	return addr
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServeMain serves the tui over ssh, every session gets a model of its own
+// and is whoever its key is configured to be
+func sshServeMain(args []string) int {
+	fs := flag.NewFlagSet("ssh-serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:2222", "address to listen on")
+	hostkey := fs.String("hostkey", "", "host key `file`, one is made in the data dir if not given")
+	open := fs.Bool("open", false, "let in keys that aren't in the config, as wanderers")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ttyxcvr ssh-serve [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	config, err := loadConfig()
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	dir, err := dataDir()
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	if *hostkey == "" {
+		*hostkey = filepath.Join(dir, "ssh_host_ed25519_key")
+	}
+	signer, err := loadHostKey(*hostkey)
+	if err != nil {
+		logger.Printf("couldn't load host key: %v", err)
+		return 1
+	}
+	if len(config.SSH) == 0 && !*open {
+		logger.Print("nobody can log in, add keys to the ssh section of the config or use --open")
+	}
+	// every session shares lipgloss's default renderer, which would
+	// otherwise go by whatever our own stdout is. nearly every terminal that
+	// ssh is run from does truecolor
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	lipgloss.SetHasDarkBackground(true)
+
+	ss := &sshServer{config: config, dir: dir, open: *open, log: logger}
+	ss.ssh = &ssh.ServerConfig{PublicKeyCallback: ss.authorize}
+	ss.ssh.AddHostKey(signer)
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	logger.Printf("serving ttyxcvr over ssh on %s, host key %s", l.Addr(), ssh.FingerprintSHA256(signer.PublicKey()))
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			logger.Print(err)
+			return 1
+		}
+		go ss.serve(conn)
+	}
+}
+
+// loadHostKey reads the host key at path, making an ed25519 one there if
+// there isn't one yet
+func loadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		block, err := ssh.MarshalPrivateKey(key, "ttyxcvr")
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(block)
+		err = os.MkdirAll(filepath.Dir(path), 0o700)
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(path, data, 0o600)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+type sshServer struct {
+	ssh    *ssh.ServerConfig
+	config *Config
+	dir    string
+	open   bool
+	log    *log.Logger
+
+	// mu guards users, the outbox and archive of everyone who has logged in,
+	// which all of their sessions share
+	mu    sync.Mutex
+	users map[string]*sshUser
+}
+
+// sshUser is what the sessions with the same name have in common. err is why
+// the outbox couldn't be loaded, which every session is told about
+type sshUser struct {
+	outbox  *Outbox
+	archive *Archive
+	err     error
+}
+
+// authorize lets in the keys in the config, or everyone when open, passing
+// the fingerprint on to the session
+func (ss *sshServer) authorize(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	fp := ssh.FingerprintSHA256(key)
+	if _, ok := ss.config.SSH[fp]; !ok && !ss.open {
+		return nil, errors.New("unknown key " + fp)
+	}
+	return &ssh.Permissions{Extensions: map[string]string{"fingerprint": fp}}, nil
+}
+
+func (ss *sshServer) serve(nconn net.Conn) {
+	defer nconn.Close()
+	conn, chans, reqs, err := ssh.NewServerConn(nconn, ss.ssh)
+	if err != nil {
+		ss.log.Printf("%s: %v", nconn.RemoteAddr(), err)
+		return
+	}
+	defer conn.Close()
+	fp := conn.Permissions.Extensions["fingerprint"]
+	ss.log.Printf("%s logged in as %s", conn.RemoteAddr(), fp)
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, creqs, err := nc.Accept()
+		if err != nil {
+			ss.log.Printf("%s: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		go ss.session(ch, creqs, fp)
+	}
+	ss.log.Printf("%s left", conn.RemoteAddr())
+}
+
+type ptyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+type windowChange struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+// session runs the tui on a session channel once it has asked for a pty and a
+// shell, and keeps it told about the size of the window
+func (ss *sshServer) session(ch ssh.Channel, reqs <-chan *ssh.Request, fp string) {
+	defer ch.Close()
+	var pty *ptyRequest
+	var p *tea.Program
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			pty = &ptyRequest{}
+			err := ssh.Unmarshal(req.Payload, pty)
+			req.Reply(err == nil, nil)
+		case "window-change":
+			var wc windowChange
+			err := ssh.Unmarshal(req.Payload, &wc)
+			if err == nil && p != nil {
+				p.Send(tea.WindowSizeMsg{Width: int(wc.Columns), Height: int(wc.Rows)})
+			}
+		case "shell":
+			if p != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			if pty == nil {
+				fmt.Fprint(ch.Stderr(), "ttyxcvr needs a terminal, try ssh -t\r\n")
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			p = ss.program(ctx, ch, pty, fp)
+			go func() {
+				ss.run(p, ch)
+				cancel()
+				ch.Close()
+			}()
+			p.Send(tea.WindowSizeMsg{Width: int(pty.Columns), Height: int(pty.Rows)})
+		default:
+			// env and the like aren't needed, exec and subsystems aren't
+			// supported
+			if req.WantReply {
+				req.Reply(req.Type == "env", nil)
+			}
+		}
+	}
+	if p != nil {
+		p.Kill()
+	}
+}
+
+// user returns what the sessions of name share, loading it for the first one
+func (ss *sshServer) user(name string) *sshUser {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if u, ok := ss.users[name]; ok {
+		return u
+	}
+	if ss.users == nil {
+		ss.users = make(map[string]*sshUser)
+	}
+	dir := filepath.Join(ss.dir, "ssh", name)
+	outbox, err := loadOutboxIn(dir)
+	u := &sshUser{outbox, openArchiveIn(dir), err}
+	ss.users[name] = u
+	return u
+}
+
+// program makes the tui for a session, as whoever fp is configured to be
+func (ss *sshServer) program(ctx context.Context, ch ssh.Channel, pty *ptyRequest, fp string) *tea.Program {
+	var p *tea.Program
+	m := ss.model(fp, func(msg tea.Msg) { p.Send(msg) })
+	p = tea.NewProgram(m,
+		tea.WithInput(ch),
+		tea.WithOutput(ch),
+		tea.WithContext(ctx),
+		tea.WithAltScreen(),
+		tea.WithoutSignalHandler(),
+		tea.WithEnvironment([]string{"TERM=" + pty.Term}),
+	)
+	if user := ss.config.SSH[fp]; user.Handle != nil && user.AppPassword != nil {
+		go p.Send(loginMsg{[]string{*user.Handle, *user.AppPassword}})
+	}
+	return p
+}
+
+// model makes the model for a session as whoever fp is configured to be
+func (ss *sshServer) model(fp string, send func(tea.Msg)) model {
+	user := ss.config.SSH[fp]
+	name := spillName(fp)
+	if user.Name != nil {
+		name = spillName(*user.Name)
+	}
+	u := ss.user(name)
+	// the hooks are the server's, they aren't for whoever is using it, so
+	// their workers aren't started at all
+	config := *ss.config
+	config.Hooks = nil
+	m, err := newModel(&config, u.outbox, u.archive, send)
+	if user.Nick != nil {
+		m.gsd.nick = user.Nick
+	}
+	if user.Color != nil {
+		m.gsd.color = user.Color
+	}
+	m.gsd.handle = user.Handle
+	var errs []error
+	if u.err != nil {
+		errs = append(errs, errors.New("couldn't load outbox: "+u.err.Error()))
+	}
+	if err != nil {
+		errs = append(errs, errors.New("couldn't load config: "+err.Error()))
+	}
+	if err := errors.Join(errs...); err != nil {
+		out := err.Error()
+		m.cmdout = &out
+	}
+	return m
+}
+
+// run runs a session's tui until it quits or the session is over
+func (ss *sshServer) run(p *tea.Program, ch ssh.Channel) {
+	fm, err := p.Run()
+	if fm, ok := fm.(model); ok {
+		fm.cm.close()
+	}
+	status := uint32(0)
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		ss.log.Print(err)
+		status = 1
+	}
+	ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
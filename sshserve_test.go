@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+// TestSSHSessionsShare checks that every session of a name gets the same
+// outbox and archive, that the server's hooks stay the server's, and that two
+// sessions can use the outbox at the same time
+func TestSSHSessionsShare(t *testing.T) {
+	ann := "ann"
+	ss := &sshServer{
+		config: &Config{
+			SSH: map[string]SSHUser{
+				"SHA256:laptop": {Name: &ann},
+				"SHA256:phone":  {Name: &ann},
+			},
+			Hooks: &HooksConfig{On: map[string][]string{hookPublish: {"true"}}},
+		},
+		dir: t.TempDir(),
+	}
+	laptop := ss.model("SHA256:laptop", nil)
+	phone := ss.model("SHA256:phone", nil)
+	stranger := ss.model("SHA256:stranger", nil)
+	if laptop.gsd.outbox != phone.gsd.outbox || laptop.gsd.archive != phone.gsd.archive {
+		t.Error("ann's sessions have outboxes and archives of their own")
+	}
+	if stranger.gsd.outbox == laptop.gsd.outbox || stranger.gsd.archive == laptop.gsd.archive {
+		t.Error("a stranger has ann's outbox or archive")
+	}
+	for _, m := range []model{laptop, phone, stranger} {
+		if m.gsd.hooks != nil {
+			t.Error("a session has the server's hooks")
+		}
+		if m.cmdout != nil {
+			t.Errorf("a session started with %s", *m.cmdout)
+		}
+	}
+
+	const each = 20
+	var wg sync.WaitGroup
+	for i, m := range []model{laptop, phone} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range each {
+				lmr := lex.MessageRecord{Body: fmt.Sprintf("%d.%d", i, j)}
+				e, err := m.gsd.outbox.add("did:plc:ann", "ws://a", nil, lmr)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// the other session may have picked it up on a login
+				for _, p := range m.gsd.outbox.pending("did:plc:ann") {
+					publishCmd(nil, p)
+				}
+				m.gsd.outbox.failed(e.ID, errors.New("nope"))
+				m.View()
+			}
+		}()
+	}
+	wg.Wait()
+	reloaded, err := loadOutboxIn(filepath.Join(ss.dir, "ssh", ann))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool)
+	for _, e := range reloaded.entries {
+		ids[e.ID] = true
+	}
+	if len(ids) != 2*each {
+		t.Errorf("the outbox has %d entries with different ids, want %d", len(ids), 2*each)
+	}
+}
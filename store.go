@@ -0,0 +1,215 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// MessageStore owns the transcript of a channel: the messages in the order
+// they were started, lookup by lrc id, and the rendered lines of each one.
+// changing a message only marks it dirty, the next layout rerenders the dirty
+// messages and recounts line offsets from the first one that changed, which is
+// almost always one of the last few, so typing doesn't cost a pass over the
+// history
+type MessageStore struct {
+	msgs   []*Message
+	render func(*Message) string
+
+	// ids maps lrc ids to sequence numbers, every message gets the next one
+	// when it is added and keeps it while it is spilled to disk. base is the
+	// sequence number of msgs[0]
+	ids  map[uint32]int
+	base int
+
+	channel string
+	limit   int
+	spill   *spill
+
+	// lines says which line of the transcript each message starts on,
+	// everything from msgs[stale] onwards is out of date
+	lines []int
+	total int
+	stale int
+}
+
+func NewMessageStore(channel string, render func(*Message) string) *MessageStore {
+	return &MessageStore{
+		ids:     make(map[uint32]int),
+		render:  render,
+		channel: channel,
+	}
+}
+
+func (s *MessageStore) Len() int {
+	return len(s.msgs)
+}
+
+// At returns the i-th message in transcript order
+func (s *MessageStore) At(i int) *Message {
+	return s.msgs[i]
+}
+
+func (s *MessageStore) Get(id uint32) *Message {
+	i, ok := s.Index(id)
+	if !ok {
+		return nil
+	}
+	return s.msgs[i]
+}
+
+// Index returns the position of the message with the given id in transcript
+// order
+func (s *MessageStore) Index(id uint32) (int, bool) {
+	seq, ok := s.ids[id]
+	return seq - s.base, ok
+}
+
+// Touch marks a message as needing to be rerendered, call it after changing
+// any of its fields from outside of the store
+func (s *MessageStore) Touch(id uint32) {
+	if i, ok := s.Index(id); ok {
+		s.touch(i)
+	}
+}
+
+// TouchAll marks every message as needing to be rerendered, for when
+// something that every render depends on changes, like the width
+func (s *MessageStore) TouchAll() {
+	for _, m := range s.msgs {
+		m.dirty = true
+	}
+	s.stale = 0
+}
+
+func (s *MessageStore) touch(i int) {
+	s.msgs[i].dirty = true
+	s.msgs[i].spilled = false
+	s.stale = min(s.stale, i)
+}
+
+func (s *MessageStore) add(id uint32, m *Message) *Message {
+	m.id = id
+	m.dirty = true
+	s.ids[id] = s.base + len(s.msgs)
+	s.msgs = append(s.msgs, m)
+	// a new message starts where the transcript ends, and it still has to
+	// be rendered
+	s.lines = append(s.lines, s.total)
+	s.stale = min(s.stale, len(s.msgs)-1)
+	return m
+}
+
+// getOrAdd returns the message with the given id, starting an empty one if
+// we missed its init
+func (s *MessageStore) getOrAdd(id uint32) *Message {
+	if m := s.Get(id); m != nil {
+		return m
+	}
+	return s.add(id, &Message{active: true})
+}
+
+// Update brings the message with lm's id up to date with it, starting it if
+// we don't have it yet. an init starts the message over, dropping anything
+// we had attached to it
+func (s *MessageStore) Update(lm *lrcclient.Message, init bool) *Message {
+	i, ok := s.Index(lm.ID)
+	var m *Message
+	switch {
+	case !ok:
+		m = s.add(lm.ID, &Message{})
+	case init:
+		m = &Message{id: lm.ID}
+		s.msgs[i] = m
+	default:
+		m = s.msgs[i]
+	}
+	m.nick = lm.Nick
+	m.handle = lm.ExternalID
+	m.color = lm.Color
+	m.active = lm.Active
+	m.text = lm.Text
+	s.Touch(lm.ID)
+	return m
+}
+
+// Each calls fn with every message in transcript order, reading back the ones
+// that have been spilled to disk, and stops at the first error
+func (s *MessageStore) Each(fn func(*Message) error) error {
+	for seq := 0; s.spill != nil && seq < s.base; seq++ {
+		m, err := s.spill.read(seq)
+		if err != nil {
+			return err
+		}
+		err = fn(m)
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range s.msgs {
+		err := fn(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layout rerenders the dirty messages and recounts line offsets from the
+// first stale message onwards
+func (s *MessageStore) layout() {
+	if s.stale >= len(s.msgs) {
+		return
+	}
+	line := 0
+	if s.stale > 0 {
+		line = s.lines[s.stale-1] + len(s.msgs[s.stale-1].lines)
+	}
+	for i := s.stale; i < len(s.msgs); i++ {
+		m := s.msgs[i]
+		if m.dirty {
+			m.lines = nil
+			if rendered := s.render(m); rendered != "" {
+				m.lines = strings.Split(strings.TrimSuffix(rendered, "\n"), "\n")
+			}
+			m.dirty = false
+		}
+		s.lines[i] = line
+		line += len(m.lines)
+	}
+	s.total = line
+	s.stale = len(s.msgs)
+}
+
+// Height returns how many lines the whole transcript takes up
+func (s *MessageStore) Height() int {
+	s.layout()
+	return s.total
+}
+
+// Window returns up to n lines of the transcript starting at line from,
+// only looking at the messages that overlap them
+func (s *MessageStore) Window(from int, n int) []string {
+	s.layout()
+	window := make([]string, 0, n)
+	i := sort.Search(len(s.msgs), func(i int) bool {
+		return s.lines[i]+len(s.msgs[i].lines) > from
+	})
+	for ; i < len(s.msgs) && len(window) < n; i++ {
+		lines := s.msgs[i].lines[max(0, from-s.lines[i]):]
+		window = append(window, lines[:min(len(lines), n-len(window))]...)
+	}
+	return window
+}
+
+// Line returns the line of the transcript that the message with the given id
+// starts on, and how many lines it takes up
+func (s *MessageStore) Line(id uint32) (int, int) {
+	i, ok := s.Index(id)
+	if !ok {
+		return 0, 0
+	}
+	s.layout()
+	return s.lines[i], len(s.msgs[i].lines)
+}
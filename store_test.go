@@ -0,0 +1,211 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/rachel-mp4/lrcproto/gen/go"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// feed applies events to a client store and brings a MessageStore up to date
+// with what they did, the way the tui does
+type feed struct {
+	lrc   *lrcclient.Store
+	store *MessageStore
+}
+
+func newFeed() *feed {
+	return &feed{
+		lrc: lrcclient.NewStore(0),
+		store: NewMessageStore("test", func(m *Message) string {
+			if m.collapsed() {
+				return ""
+			}
+			return *m.nick + ": " + m.text
+		}),
+	}
+}
+
+func (f *feed) apply(tb testing.TB, e *lrcpb.Event) {
+	tb.Helper()
+	ev, err := f.lrc.Apply(e)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if ev.Message != nil {
+		f.store.Update(ev.Message, ev.Kind == lrcclient.KindInit)
+	}
+}
+
+func (f *feed) init(tb testing.TB, id uint32, nick string) {
+	f.apply(tb, &lrcpb.Event{Msg: &lrcpb.Event_Init{Init: &lrcpb.Init{Id: &id, Nick: &nick}}})
+}
+
+func (f *feed) insert(tb testing.TB, id uint32, at uint32, body string) {
+	f.apply(tb, &lrcpb.Event{Msg: &lrcpb.Event_Insert{Insert: &lrcpb.Insert{Id: &id, Body: body, Utf16Index: at}}})
+}
+
+func (f *feed) pub(tb testing.TB, id uint32) {
+	f.apply(tb, &lrcpb.Event{Msg: &lrcpb.Event_Pub{Pub: &lrcpb.Pub{Id: &id}}})
+}
+
+func (f *feed) transcript() []string {
+	return f.store.Window(0, f.store.Height())
+}
+
+func TestMessageStoreTranscript(t *testing.T) {
+	f := newFeed()
+	f.init(t, 1, "ann")
+	f.init(t, 2, "bob")
+	f.init(t, 3, "cat")
+	want := []string{"ann: ", "bob: ", "cat: "}
+	if got := f.transcript(); !slices.Equal(got, want) {
+		t.Fatalf("after inits got %q, want %q", got, want)
+	}
+
+	f.insert(t, 2, 0, "hi")
+	f.insert(t, 1, 0, "hello\nthere")
+	f.insert(t, 2, 2, " all")
+	f.pub(t, 2)
+	want = []string{"ann: hello", "there", "bob: hi all", "cat: "}
+	if got := f.transcript(); !slices.Equal(got, want) {
+		t.Fatalf("after edits got %q, want %q", got, want)
+	}
+	if line, n := f.store.Line(2); line != 2 || n != 1 {
+		t.Errorf("bob's message is at line %d with %d lines, want 2 and 1", line, n)
+	}
+
+	// a message pub'd with nothing in it takes up no room
+	f.pub(t, 3)
+	f.init(t, 4, "dan")
+	f.insert(t, 4, 0, "yo")
+	want = []string{"ann: hello", "there", "bob: hi all", "dan: yo"}
+	if got := f.transcript(); !slices.Equal(got, want) {
+		t.Fatalf("after collapsing got %q, want %q", got, want)
+	}
+	if got := f.store.Window(1, 2); !slices.Equal(got, want[1:3]) {
+		t.Errorf("window got %q, want %q", got, want[1:3])
+	}
+}
+
+func (f *feed) delete(tb testing.TB, id uint32, start uint32, end uint32) {
+	f.apply(tb, &lrcpb.Event{Msg: &lrcpb.Event_Delete{Delete: &lrcpb.Delete{Id: &id, Utf16Start: start, Utf16End: end}}})
+}
+
+// channelOf makes a feed with n pub'd messages and one more being typed at
+// the end, laid out as if it had been on screen all along
+func channelOf(b *testing.B, n int) *feed {
+	f := newFeed()
+	for i := range uint32(n) {
+		f.init(b, i, "someone")
+		f.insert(b, i, 0, "a message that was sent a while ago")
+		f.pub(b, i)
+	}
+	f.init(b, uint32(n), "me")
+	f.store.Height()
+	return f
+}
+
+var benchSizes = []struct {
+	name string
+	n    int
+}{
+	{"1k", 1000},
+	{"10k", 10000},
+}
+
+// typeOne keeps adding and removing a character at the end of the message being
+// typed, so that it doesn't grow over the run
+func (f *feed) typeOne(b *testing.B, i int) {
+	id := uint32(f.store.Len() - 1)
+	if i%2 == 0 {
+		f.insert(b, id, 0, "x")
+	} else {
+		f.delete(b, id, 0, 1)
+	}
+}
+
+// the cost of an event and laying out the transcript after it shouldn't
+// depend on how long the channel is
+func BenchmarkInsert(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			f := channelOf(b, size.n)
+			b.ResetTimer()
+			for i := range b.N {
+				f.typeOne(b, i)
+				f.store.Height()
+			}
+		})
+	}
+}
+
+// BenchmarkRedraw is an event followed by drawing the bottom of the
+// transcript, which is what the tui does for every keystroke in the channel
+func BenchmarkRedraw(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			f := channelOf(b, size.n)
+			t := newTranscript(f.store, 80, 40)
+			t.Refresh()
+			t.GotoBottom()
+			b.ResetTimer()
+			for i := range b.N {
+				f.typeOne(b, i)
+				t.Refresh()
+				t.View()
+			}
+		})
+	}
+}
+
+// BenchmarkWindow is reading a screenful of lines from the middle of the
+// transcript once it is laid out
+func BenchmarkWindow(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			f := channelOf(b, size.n)
+			from := f.store.Height() / 2
+			b.ResetTimer()
+			for range b.N {
+				f.store.Window(from, 40)
+			}
+		})
+	}
+}
+
+// TestMessageStoreReusedID spills and pages back in a message whose lrc id
+// has since been given to a newer one, which has to keep the id
+func TestMessageStoreReusedID(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	f := newFeed()
+	defer f.store.Close()
+	f.store.SetScrollback(2)
+	for i, nick := range []string{"ann", "bob", "cat"} {
+		id := uint32(i + 1)
+		f.init(t, id, nick)
+		f.insert(t, id, 0, "old")
+		f.pub(t, id)
+	}
+	if removed := f.store.trim(1 << 30); removed != 1 {
+		t.Fatalf("trim removed %d lines, want 1", removed)
+	}
+	f.init(t, 1, "dan")
+	f.insert(t, 1, 0, "new")
+	f.pub(t, 1)
+	if added := f.store.PageIn(10); added != 1 {
+		t.Fatalf("paging in added %d lines, want 1", added)
+	}
+	if m := f.store.Get(1); m == nil || m.text != "new" {
+		t.Errorf("after paging in, id 1 is %+v, want dan's", m)
+	}
+	want := []string{"ann: old", "bob: old", "cat: old", "dan: new"}
+	if got := f.transcript(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	f.store.trim(1 << 30)
+	if m := f.store.Get(1); m == nil || m.text != "new" {
+		t.Errorf("after spilling again, id 1 is %+v, want dan's", m)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// transcript is a viewport over a MessageStore. unlike viewport.Model it never
+// holds the whole transcript as one string, it only asks the store for the
+// lines that are on screen, so an event in a long channel costs about as much
+// as an event in an empty one
+type transcript struct {
+	store   *MessageStore
+	Width   int
+	Height  int
+	YOffset int
+	KeyMap  viewport.KeyMap
+
+	MouseWheelEnabled bool
+	// MouseWheelDelta is how many lines a turn of the wheel scrolls
+	MouseWheelDelta int
+
+	// total is the height of the transcript as of the last Refresh, so that
+	// AtBottom answers for what is on screen rather than what is about to be
+	total int
+}
+
+func newTranscript(store *MessageStore, width int, height int) transcript {
+	return transcript{
+		store:             store,
+		Width:             width,
+		Height:            height,
+		KeyMap:            viewport.DefaultKeyMap(),
+		MouseWheelEnabled: true,
+		MouseWheelDelta:   3,
+	}
+}
+
+// Refresh picks up changes to the store, like SetContent does for a viewport.
+// it is also when the store gets to spill whatever has scrolled off the top
+func (t *transcript) Refresh() {
+	t.YOffset = max(0, t.YOffset-t.store.trim(t.YOffset))
+	t.total = t.store.Height()
+	if t.YOffset > t.maxYOffset() {
+		t.GotoBottom()
+	}
+}
+
+func (t transcript) maxYOffset() int {
+	return max(0, t.total-t.Height)
+}
+
+func (t transcript) AtTop() bool {
+	return t.YOffset <= 0
+}
+
+func (t transcript) AtBottom() bool {
+	return t.YOffset >= t.maxYOffset()
+}
+
+func (t *transcript) SetYOffset(n int) {
+	t.YOffset = max(0, min(n, t.maxYOffset()))
+}
+
+func (t *transcript) GotoTop() {
+	t.SetYOffset(0)
+}
+
+func (t *transcript) GotoBottom() {
+	t.SetYOffset(t.maxYOffset())
+}
+
+func (t *transcript) ScrollDown(n int) {
+	t.SetYOffset(t.YOffset + n)
+}
+
+func (t *transcript) ScrollUp(n int) {
+	if t.YOffset < n {
+		t.pageIn()
+	}
+	t.SetYOffset(t.YOffset - n)
+}
+
+// pageIn brings back a page of spilled messages above the top of the
+// transcript without moving what is on screen, and reports whether there were
+// any
+func (t *transcript) pageIn() bool {
+	added := t.store.PageIn(scrollbackPage)
+	if added == 0 {
+		return false
+	}
+	t.total = t.store.Height()
+	t.YOffset += added
+	return true
+}
+
+func (t transcript) Update(msg tea.Msg) (transcript, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, t.KeyMap.PageDown):
+			t.ScrollDown(t.Height)
+		case key.Matches(msg, t.KeyMap.PageUp):
+			t.ScrollUp(t.Height)
+		case key.Matches(msg, t.KeyMap.HalfPageDown):
+			t.ScrollDown(t.Height / 2)
+		case key.Matches(msg, t.KeyMap.HalfPageUp):
+			t.ScrollUp(t.Height / 2)
+		case key.Matches(msg, t.KeyMap.Down):
+			t.ScrollDown(1)
+		case key.Matches(msg, t.KeyMap.Up):
+			t.ScrollUp(1)
+		}
+	case tea.MouseMsg:
+		if !t.MouseWheelEnabled || msg.Action != tea.MouseActionPress {
+			break
+		}
+		switch msg.Button {
+		case tea.MouseButtonWheelDown:
+			t.ScrollDown(t.MouseWheelDelta)
+		case tea.MouseButtonWheelUp:
+			t.ScrollUp(t.MouseWheelDelta)
+		}
+	}
+	return t, nil
+}
+
+func (t transcript) View() string {
+	lines := t.store.Window(t.YOffset, t.Height)
+	return lipgloss.NewStyle().
+		Width(t.Width).
+		Height(t.Height).
+		MaxHeight(t.Height).
+		MaxWidth(t.Width).
+		Render(strings.Join(lines, "\n"))
+}
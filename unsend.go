@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rachel-mp4/ttyxcvr/lrcclient"
+)
+
+// moveSelection moves the selected message by delta messages, selecting the
+// most recent message if nothing was selected yet
+func (cm *channelmodel) moveSelection(delta int) {
+	if cm.store.Len() == 0 {
+		return
+	}
+	idx := cm.store.Len() - 1
+	if cm.selected != nil {
+		cur, ok := cm.store.Index(*cm.selected)
+		if ok && cur+delta < 0 && cm.vp.pageIn() {
+			cur, ok = cm.store.Index(*cm.selected)
+		}
+		if ok {
+			idx = max(0, min(cm.store.Len()-1, cur+delta))
+		}
+		cm.clearSelection()
+	}
+	// collapsed messages can't be seen, so they are stepped over
+	step := -1
+	if delta > 0 {
+		step = 1
+	}
+	for idx >= 0 && idx < cm.store.Len() && cm.store.At(idx).collapsed() {
+		idx += step
+	}
+	if idx < 0 || idx >= cm.store.Len() {
+		return
+	}
+	m := cm.store.At(idx)
+	id := m.id
+	cm.selected = &id
+	m.selected = true
+	cm.store.Touch(id)
+	cm.redraw()
+	cm.scrollToMessage(id)
+}
+
+func (cm *channelmodel) clearSelection() {
+	if cm.selected == nil {
+		return
+	}
+	if m := cm.store.Get(*cm.selected); m != nil {
+		m.selected = false
+		cm.store.Touch(m.id)
+		cm.redraw()
+	}
+	cm.selected = nil
+}
+
+// scrollToMessage scrolls the viewport just far enough that the header of the
+// message is visible
+func (cm *channelmodel) scrollToMessage(id uint32) {
+	line, height := cm.store.Line(id)
+	if line < cm.vp.YOffset {
+		cm.vp.SetYOffset(line)
+	} else if line >= cm.vp.YOffset+cm.vp.Height {
+		cm.vp.SetYOffset(line - cm.vp.Height + height)
+	}
+}
+
+func (m model) unsend() (tea.Model, tea.Cmd) {
+	var out string
+	switch {
+	case m.cm == nil || m.cm.selected == nil:
+		out = "select one of your messages with J/K first"
+	case m.gsd.xrpc == nil:
+		out = "you need to :login before you can unsend"
+	}
+	if out != "" {
+		m.cmdout = &out
+		return m, nil
+	}
+	id := *m.cm.selected
+	message := m.cm.store.Get(id)
+	if message == nil || message.uri == nil {
+		out = "that message was never published"
+		m.cmdout = &out
+		return m, nil
+	}
+	did, err := DidFromUri(*message.uri)
+	if err != nil {
+		out = err.Error()
+		m.cmdout = &out
+		return m, nil
+	}
+	if !strings.EqualFold(did, m.gsd.xrpc.DID()) {
+		out = "you can only unsend your own messages"
+		m.cmdout = &out
+		return m, nil
+	}
+	rkey, err := RkeyFromUri(*message.uri)
+	if err != nil {
+		out = err.Error()
+		m.cmdout = &out
+		return m, nil
+	}
+	return m, unsendCmd(m.gsd.xrpc, rkey, message.cid, id, m.cm.wsurl)
+}
+
+func unsendCmd(xrpc *lrcclient.PasswordClient, rkey string, cid *string, id uint32, wsurl string) tea.Cmd {
+	return func() tea.Msg {
+		err := xrpc.DeleteXCVRMessage(rkey, cid, context.Background())
+		if err != nil {
+			return unsendFailedMsg{err}
+		}
+		return unsentMsg{id, wsurl}
+	}
+}
+
+type unsendMsg struct{}
+
+type unsentMsg struct {
+	id    uint32
+	wsurl string
+}
+
+type unsendFailedMsg struct {
+	err error
+}
@@ -0,0 +1,173 @@
+// Package xcvr is a small typed client for the org.xcvr appview. it
+// implements util.LexClient so the generated stubs in the lex package can be
+// called through it, and wraps each of them in a method
+package xcvr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rachel-mp4/ttyxcvr/lex"
+)
+
+const DefaultHost = "https://xcvr.org"
+const DefaultTimeout = 10 * time.Second
+
+// Client talks to a single appview. Clients are cheap to copy, WithHost shares
+// the underlying http.Client so connections get reused between hosts
+type Client struct {
+	Host      string
+	HTTP      *http.Client
+	Dialer    *websocket.Dialer
+	UserAgent string
+}
+
+func NewClient(host string) *Client {
+	return &Client{
+		Host:      strings.TrimSuffix(host, "/"),
+		HTTP:      &http.Client{Timeout: DefaultTimeout},
+		Dialer:    &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: DefaultTimeout},
+		UserAgent: "ttyxcvr",
+	}
+}
+
+// WithHost returns a copy of c that sends its requests to host instead
+func (c *Client) WithHost(host string) *Client {
+	cc := *c
+	cc.Host = strings.TrimSuffix(host, "/")
+	return &cc
+}
+
+// Error is what an appview sends back when a request fails, see
+// https://atproto.com/specs/xrpc#error-responses
+type Error struct {
+	StatusCode int    `json:"-"`
+	Name       string `json:"error"`
+	Message    string `json:"message,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("xrpc error: http %d", e.StatusCode)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("xrpc error: %s (http %d)", e.Name, e.StatusCode)
+	}
+	return fmt.Sprintf("xrpc error: %s: %s (http %d)", e.Name, e.Message, e.StatusCode)
+}
+
+func (c *Client) endpoint(nsid string, params map[string]any) string {
+	u := fmt.Sprintf("%s/xrpc/%s", c.Host, nsid)
+	if len(params) == 0 {
+		return u
+	}
+	values := url.Values{}
+	for k, v := range params {
+		switch v := v.(type) {
+		case []string:
+			for _, s := range v {
+				values.Add(k, s)
+			}
+		default:
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return u + "?" + values.Encode()
+}
+
+// LexDo implements util.LexClient
+func (c *Client) LexDo(ctx context.Context, method string, inputEncoding string, nsid string, params map[string]any, bodyData any, out any) error {
+	var body io.Reader
+	if bodyData != nil {
+		if r, ok := bodyData.(io.Reader); ok {
+			body = r
+		} else {
+			b, err := json.Marshal(bodyData)
+			if err != nil {
+				return err
+			}
+			body = bytes.NewReader(b)
+			if inputEncoding == "" {
+				inputEncoding = "application/json"
+			}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(nsid, params), body)
+	if err != nil {
+		return err
+	}
+	if inputEncoding != "" {
+		req.Header.Set("Content-Type", inputEncoding)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		xerr := &Error{StatusCode: res.StatusCode}
+		data, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+		if err != nil || json.Unmarshal(data, xerr) != nil || xerr.Name == "" {
+			// not an xrpc error body, like a proxy's error page, so say
+			// what the status was along with whatever text came back
+			xerr.Name = http.StatusText(res.StatusCode)
+			xerr.Message = strings.TrimSpace(string(data))
+			if runes := []rune(xerr.Message); len(runes) > 200 {
+				xerr.Message = string(runes[:200]) + "..."
+			}
+		}
+		return xerr
+	}
+	if out == nil {
+		return nil
+	}
+	if w, ok := out.(io.Writer); ok {
+		_, err = io.Copy(w, res.Body)
+		return err
+	}
+	err = json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", nsid, err)
+	}
+	return nil
+}
+
+// GetChannels lists every channel the appview knows about
+func (c *Client) GetChannels(ctx context.Context) ([]*lex.ChannelView, error) {
+	return lex.GetChannels(ctx, c)
+}
+
+// ResolveChannel asks the appview for the websocket that hosts the channel
+// record did/org.xcvr.feed.channel/rkey
+func (c *Client) ResolveChannel(ctx context.Context, did string, rkey string) (*lex.ResolveChannel_Output, error) {
+	return lex.ResolveChannel(ctx, c, did, rkey)
+}
+
+// SubscribeLexStream dials the lex stream for the channel with the at-uri uri,
+// messages can be read off of the connection as lex.SubscribeLexStream_Message
+func (c *Client) SubscribeLexStream(ctx context.Context, uri string) (*websocket.Conn, error) {
+	host := c.Host
+	switch {
+	case strings.HasPrefix(host, "https://"):
+		host = "wss://" + strings.TrimPrefix(host, "https://")
+	case strings.HasPrefix(host, "http://"):
+		host = "ws://" + strings.TrimPrefix(host, "http://")
+	}
+	conn, res, err := c.Dialer.DialContext(ctx, lex.SubscribeLexStreamURL(host, uri), http.Header{"User-Agent": []string{c.UserAgent}})
+	if err != nil && res != nil {
+		return nil, fmt.Errorf("subscribing to lex stream: %w (http %d)", err, res.StatusCode)
+	}
+	return conn, err
+}
@@ -0,0 +1,104 @@
+package xcvr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLexDoErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        Error
+	}{
+		{
+			"xrpc",
+			http.StatusBadRequest, "application/json",
+			`{"error":"InvalidRequest","message":"no channel like that"}`,
+			Error{http.StatusBadRequest, "InvalidRequest", "no channel like that"},
+		},
+		{
+			"xrpc without a message",
+			http.StatusUnauthorized, "application/json",
+			`{"error":"AuthRequired"}`,
+			Error{http.StatusUnauthorized, "AuthRequired", ""},
+		},
+		{
+			"html",
+			http.StatusBadGateway, "text/html",
+			"<html><body><h1>502 Bad Gateway</h1></body></html>\n",
+			Error{http.StatusBadGateway, "Bad Gateway", "<html><body><h1>502 Bad Gateway</h1></body></html>"},
+		},
+		{
+			"plain text",
+			http.StatusServiceUnavailable, "text/plain",
+			"  down for maintenance  ",
+			Error{http.StatusServiceUnavailable, "Service Unavailable", "down for maintenance"},
+		},
+		{
+			"json that isn't an xrpc error",
+			http.StatusInternalServerError, "application/json",
+			`{"status":"broken"}`,
+			Error{http.StatusInternalServerError, "Internal Server Error", `{"status":"broken"}`},
+		},
+		{
+			"empty",
+			http.StatusNotFound, "text/plain",
+			"",
+			Error{http.StatusNotFound, "Not Found", ""},
+		},
+		{
+			"long",
+			http.StatusBadGateway, "text/plain",
+			strings.Repeat("é", 300),
+			Error{http.StatusBadGateway, "Bad Gateway", strings.Repeat("é", 200) + "..."},
+		},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/xrpc/org.xcvr.feed.getChannels" {
+				t.Errorf("%s: request for %s", tt.name, r.URL.Path)
+			}
+			w.Header().Set("Content-Type", tt.contentType)
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		_, err := NewClient(srv.URL).GetChannels(context.Background())
+		srv.Close()
+		var xerr *Error
+		if !errors.As(err, &xerr) {
+			t.Errorf("%s: %v isn't an *Error", tt.name, err)
+			continue
+		}
+		if *xerr != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *xerr, tt.want)
+		}
+	}
+}
+
+func TestLexDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query(); got.Get("did") != "did:plc:abc" || got.Get("rkey") != "3k" {
+			t.Errorf("query is %v", got)
+		}
+		if ua := r.Header.Get("User-Agent"); ua != "ttyxcvr" {
+			t.Errorf("user agent is %q", ua)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"url":"wss://xcvr.org/lrc/3k","uri":"at://did:plc:abc/org.xcvr.feed.channel/3k"}`))
+	}))
+	defer srv.Close()
+	out, err := NewClient(srv.URL+"/").ResolveChannel(context.Background(), "did:plc:abc", "3k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.URL != "wss://xcvr.org/lrc/3k" || out.URI == nil || *out.URI != "at://did:plc:abc/org.xcvr.feed.channel/3k" {
+		t.Errorf("got %+v", out)
+	}
+}